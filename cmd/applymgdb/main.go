@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/delta"
+)
+
+func main() {
+	cliArgs := os.Args
+	fmt.Println(cliArgs)
+	if len(cliArgs) < 3 || len(cliArgs) > 4 {
+		fmt.Println("Usage: applymgdb {base.mgdb} {patch.mgdbpatch} [{out.mgdb}]")
+		fmt.Println("Without out.mgdb the base MGDB is replaced once the patched copy is verified")
+		return
+	}
+	basePath := cliArgs[1]
+	outPath := basePath
+	if len(cliArgs) == 4 {
+		outPath = cliArgs[3]
+	}
+
+	summary, err := delta.Apply(basePath, cliArgs[2], outPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Applied %v row changes and %v images, %v -> %v\n",
+		summary.Rows, summary.Blobs, summary.Info.BaseName, summary.Info.ResultName)
+	fmt.Println("Verified", outPath)
+	if summary.IndexedRoms > 0 {
+		fmt.Printf("Kept %v indexed ROMs\n", summary.IndexedRoms)
+	}
+	if summary.LostRoms > 0 {
+		fmt.Printf("%v indexed ROMs lost their game, run indexmgdb again to match them\n", summary.LostRoms)
+	}
+}
@@ -1,51 +1,147 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/collision"
 	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/config"
 	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/gamelist"
 	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/overrides"
 	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/rdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/romname"
 	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
-	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/utils"
 )
 
 func main() {
-
-	cliArgs := os.Args
-	fmt.Println(cliArgs)
-	if len(cliArgs) < 2 {
+	failOnCollision := flag.Bool("fail-on-collision", false, "exit non-zero when unresolved slug collisions are found")
+	jobs := flag.Int("jobs", 1, "DataConfigs built concurrently, each into its own MGDB")
+	workers := flag.Int("workers", runtime.NumCPU(), "image load and hash workers per build")
+	recompress := flag.Bool("recompress-png", false, "re-encode PNG images at best compression when smaller")
+	flag.Parse()
+
+	fmt.Println(os.Args)
+	cliArgs := flag.Args()
+	if len(cliArgs) < 1 {
 		fmt.Println("No DataConfig key argument provided")
 		return
 	}
-	configKey := cliArgs[1]
+	configKey := cliArgs[0]
+	imageOptions := sqlite.ImageOptions{Workers: *workers, Recompress: *recompress}
 
-	// keyword to process all in sequence
+	keys := []string{}
+	// keyword to process all
 	if configKey == "all" {
-		for _, dataConfig := range config.DataConfigs {
-			buildMGDB(dataConfig)
+		for key := range config.DataConfigs {
+			keys = append(keys, key)
 		}
-		return
+		sort.Strings(keys)
+	} else {
+		// Else try single
+		if _, ok := config.DataConfigs[configKey]; !ok {
+			fmt.Println("Invalid DataConfig key")
+			return
+		}
+		keys = append(keys, configKey)
 	}
 
-	// Else try single
-	dataConfig, ok := config.DataConfigs[configKey]
-	if !ok {
-		fmt.Println("Invalid DataConfig key")
-		return
+	results := buildAll(keys, *jobs, imageOptions)
+	printResults(results)
+
+	collisions := 0
+	failed := 0
+	for _, result := range results {
+		collisions += result.Collisions
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("%v of %v builds failed\n", failed, len(results))
+		os.Exit(1)
+	}
+	if *failOnCollision && collisions > 0 {
+		fmt.Printf("Failing on %v unresolved slug collisions\n", collisions)
+		os.Exit(1)
+	}
+}
+
+// buildResult is one DataConfig's build outcome for the final summary
+type buildResult struct {
+	Key        string
+	Skipped    bool
+	Games      int
+	Images     sqlite.ImageStats
+	Collisions int // unresolved slug collisions
+	Duration   time.Duration
+	Err        error
+}
+
+// buildAll runs up to jobs builds at once. Every build writes its own MGDB
+// and reports, so builds share nothing but stdout. Results keep key order.
+func buildAll(keys []string, jobs int, imageOptions sqlite.ImageOptions) []buildResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+	results := make([]buildResult, len(keys))
+	sem := make(chan struct{}, jobs)
+	wg := sync.WaitGroup{}
+	for i, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = buildMGDB(key, config.DataConfigs[key], imageOptions)
+		}(i, key)
+	}
+	wg.Wait()
+	return results
+}
+
+func printResults(results []buildResult) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SYSTEM\tSTATUS\tGAMES\tIMAGES\tBLOBS\tMISSING\tCOLLISIONS\tTIME\tERROR")
+	for _, result := range results {
+		status := "ok"
+		if result.Skipped {
+			status = "skipped"
+		}
+		errText := ""
+		if result.Err != nil {
+			status = "failed"
+			errText = result.Err.Error()
+		}
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			result.Key, status, result.Games, result.Images.Images, result.Images.Blobs, result.Images.Missing,
+			result.Collisions, result.Duration.Round(time.Millisecond), errText)
 	}
-	buildMGDB(dataConfig)
+	writer.Flush()
 }
 
-func buildMGDB(dataConfig config.DataConfig) {
+// buildMGDB builds one DataConfig, a panic fails only this build
+func buildMGDB(key string, dataConfig config.DataConfig, imageOptions sqlite.ImageOptions) (result buildResult) {
+	result.Key = key
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			result.Err = fmt.Errorf("%v", r)
+		}
+		result.Duration = time.Since(start)
+	}()
+
 	dirPath := config.CommandRootPath
 	coresPath := filepath.Join(dirPath, "cores")
 	coreDir := dataConfig.ScrapeFolder
@@ -69,20 +165,29 @@ func buildMGDB(dataConfig config.DataConfig) {
 		Description:        "Compiled for MiSTer_Games_GUI by @BossRighteous.\nMedia courtesy https://screenscraper.fr/ contributors and sources made available under Create Commons Attribution-NonCommercial-ShareAlike 4.0 International.\nROM data courtesy Libretro under Creative Commons Attribution-ShareAlike 4.0 International.",
 	}
 
-	// Open gameslist.xml
-	gamelistFile, err := os.Open(filepath.Join(corePath, "gamelist.xml"))
+	// Record which libretro-database revision the RDB came from
+	if state, ok := rdb.LoadFetchState(filepath.Join(corePath, "libretro.rdb")); ok {
+		dbInfo.RdbRevision = state.Revision
+		dbInfo.RdbSHA256 = state.SHA256
+	}
+
+	// Scraped metadata from gamelist.xml, LaunchBox or Pegasus
+	gamelist, source, err := gamelist.LoadSource(corePath)
 	if err != nil {
-		fmt.Println("Unable to open gamelist.xml file")
-		panic(err)
+		fmt.Println("Unable to load gamelist source, skipping", coreDir, err)
+		result.Skipped = true
+		return result
 	}
-	gamelistBytes, err := io.ReadAll(gamelistFile)
-	if err != nil && err != io.EOF {
-		fmt.Println("Unable to read gamelist.xml file")
+	fmt.Printf("Loaded %v games from %v source\n", len(gamelist.Games), source.Name())
+
+	// Curated overrides survive rebuilds, unlike edits to gamelist.xml
+	ovr, err := overrides.Load(corePath)
+	if err != nil {
+		fmt.Println("Unable to load overrides.json")
 		panic(err)
 	}
-
-	// Parse gamelist into usable structs
-	gamelist := gamelist.ParseGamelist(gamelistBytes)
+	report := buildReport{CollectionName: mgdbFilename, OverridesApplied: []overrides.Applied{}}
+	detector := collision.NewDetector(dataConfig.Systems, dataConfig.Naming)
 
 	reindexedGames := []mgdb.Game{
 		{
@@ -95,6 +200,7 @@ func buildMGDB(dataConfig config.DataConfig) {
 	gameMap[0] = 0
 	slugRomMap := make(map[string]mgdb.SlugRom)
 	slugRomMap[""] = mgdb.SlugRom{}
+	gameSlugMap := make(map[int]string) // [gameId]first slug owned by game
 
 	reindexedGenres := []mgdb.Genre{
 		{GenreID: 0, Name: "~Unknown"},
@@ -119,13 +225,12 @@ func buildMGDB(dataConfig config.DataConfig) {
 	screenshotMap[0] = ""
 	titleScreenMap := make(map[int]string) //[gameId]imagePath
 	titleScreenMap[0] = ""
-	blobHashMap := make(map[string]bool) // [hash]exists
 
 	reAscii := regexp.MustCompile("[[:^ascii:]]")
 
 	// Reorganize into table maps by game.ID
 	for _, game := range gamelist.Games {
-		fmt.Printf("%+v\n", game)
+		detector.AddGamelistGame(game)
 
 		glGameID, err := strconv.Atoi(game.ID)
 		if err != nil || glGameID == 0 {
@@ -202,19 +307,18 @@ func buildMGDB(dataConfig config.DataConfig) {
 
 		//slugGameMap()
 
-		// configure romname as filename without extension
-		fileBase := filepath.Base(game.Path)
-		fileExt := filepath.Ext(game.Path)
-		filename, _ := utils.CutSuffix(fileBase, fileExt)
-
 		// Slug is primary filename matcher to game
-		slug := utils.SlugifyString(filename)
+		fileBase := filepath.Base(game.Path)
+		slug := romname.Slugify(fileBase, dataConfig.Naming)
 		if _, ok := slugRomMap[slug]; !ok {
 			slugRomMap[slug] = mgdb.SlugRom{
 				Slug:               slug,
 				GameID:             gameID,
 				SupportedSystemIds: "",
 			}
+			if _, ok := gameSlugMap[gameID]; !ok {
+				gameSlugMap[gameID] = slug
+			}
 		}
 
 		// For initial Map, save full path, will read bytes and decompose later
@@ -228,25 +332,119 @@ func buildMGDB(dataConfig config.DataConfig) {
 		}
 	}
 
+	// Resolve override targets by gamelist ID to reindexed GameID
+	overrideGameID := func(externalID string) (int, bool) {
+		glGameID, err := strconv.Atoi(externalID)
+		if err != nil {
+			return 0, false
+		}
+		gameID, ok := gameMap[glGameID]
+		return gameID, ok && gameID != 0
+	}
+
+	// Slug target for CRC and filename pins: the game's own SlugRom, or one
+	// from its name when its filename slug already belongs to another game
+	pinSlug := func(gameID int) (string, bool) {
+		if slug, ok := gameSlugMap[gameID]; ok {
+			return slug, true
+		}
+		slug := romname.Slugify(reindexedGames[gameID].Name, dataConfig.Naming)
+		if _, ok := slugRomMap[slug]; ok || slug == "" {
+			return "", false
+		}
+		slugRomMap[slug] = mgdb.SlugRom{Slug: slug, GameID: gameID}
+		gameSlugMap[gameID] = slug
+		return slug, true
+	}
+
+	for slug, externalID := range ovr.Slug {
+		gameID, ok := overrideGameID(externalID)
+		if !ok {
+			fmt.Printf("Override slug %v: unknown game ID %v, skipping\n", slug, externalID)
+			continue
+		}
+		detail := "new slug"
+		if existing, ok := slugRomMap[slug]; ok {
+			detail = fmt.Sprintf("was %v", reindexedGames[existing.GameID].ExternalID)
+			if gameSlugMap[existing.GameID] == slug {
+				delete(gameSlugMap, existing.GameID)
+			}
+		}
+		slugRomMap[slug] = mgdb.SlugRom{Slug: slug, GameID: gameID}
+		if _, ok := gameSlugMap[gameID]; !ok {
+			gameSlugMap[gameID] = slug
+		}
+		report.OverridesApplied = append(report.OverridesApplied, overrides.Applied{
+			Kind: overrides.KindSlug, Key: slug, ExternalID: externalID, Detail: detail,
+		})
+	}
+
 	rdbRoms, rdbErr := rdb.LoadNDJSON(corePath)
 	romCrs := []mgdb.RomCrc{}
+	romTags := []mgdb.RomTag{}
+	rdbCrcs := make(map[string]bool) // [lowercase crc]in RDB
 	if rdbErr == nil {
 		for _, rom := range rdbRoms {
-			slug := utils.SlugifyString(rom.RomName)
+			detector.AddRdbRom(rom)
+			rdbCrcs[strings.ToLower(rom.CRC)] = true
+
+			// CRC and filename pins are exact, slug pins were applied above
+			if applied, ok := ovr.Match(rom.RomName, rom.CRC, ""); ok {
+				if gameID, ok := overrideGameID(applied.ExternalID); !ok {
+					fmt.Printf("Override %v %v: unknown game ID %v, skipping\n", applied.Kind, applied.Key, applied.ExternalID)
+				} else if slug, ok := pinSlug(gameID); !ok {
+					fmt.Printf("Override %v %v: game %v has no slug of its own, pin its slug instead\n", applied.Kind, applied.Key, applied.ExternalID)
+				} else {
+					applied.Detail = rom.RomName
+					report.OverridesApplied = append(report.OverridesApplied, applied)
+					romCrs = append(romCrs, mgdb.RomCrc{CRC32: rom.CRC, Slug: slug})
+					romTags = append(romTags, romname.Parse(rom.RomName, dataConfig.Naming).RomTag(rom.RomName, rom.CRC))
+					continue
+				}
+			}
+
+			slug := romname.Slugify(rom.RomName, dataConfig.Naming)
 			if slugRom, ok := slugRomMap[slug]; ok {
 				romCrs = append(romCrs, mgdb.RomCrc{CRC32: rom.CRC, Slug: slugRom.Slug})
+				romTags = append(romTags, romname.Parse(rom.RomName, dataConfig.Naming).RomTag(rom.RomName, rom.CRC))
 			}
 		}
 	} else {
 		fmt.Println("error loading ndjson, skipping CRCs")
 	}
 
+	// CRC pins of dumps missing from the RDB still match files when indexed,
+	// filename pins of those are applied by indexmgdb from overrides.json
+	pinnedCrcs := []string{}
+	for crc := range ovr.Crc {
+		if !rdbCrcs[crc] {
+			pinnedCrcs = append(pinnedCrcs, crc)
+		}
+	}
+	sort.Strings(pinnedCrcs)
+	for _, crc := range pinnedCrcs {
+		externalID := ovr.Crc[crc]
+		if gameID, ok := overrideGameID(externalID); !ok {
+			fmt.Printf("Override crc %v: unknown game ID %v, skipping\n", crc, externalID)
+		} else if slug, ok := pinSlug(gameID); !ok {
+			fmt.Printf("Override crc %v: game %v has no slug of its own, pin its slug instead\n", crc, externalID)
+		} else {
+			romCrs = append(romCrs, mgdb.RomCrc{CRC32: strings.ToUpper(crc), Slug: slug})
+			report.OverridesApplied = append(report.OverridesApplied, overrides.Applied{
+				Kind: overrides.KindCrc, Key: crc, ExternalID: externalID, Detail: "not in RDB",
+			})
+		}
+	}
+
+	report.Collisions = detector.Collisions(ovr.Slug)
+
 	dbPath := filepath.Join(corePath, mgdbFilename+".mgdb")
 	db, err := sqlite.CreateMGDB(dbPath)
 	if err != nil {
 		fmt.Println("Unable to allocate DB at ", dbPath)
 		panic(err)
 	}
+	defer db.Close()
 
 	sqlite.InsertMGDBInfo(db, dbInfo)
 	sqlite.BulkInsertGames(db, reindexedGames)
@@ -255,9 +453,63 @@ func buildMGDB(dataConfig config.DataConfig) {
 	sqlite.BulkInsertDevelopers(db, reindexedDevelopers)
 	sqlite.BulkInsertPublishers(db, reindexedPublishers)
 	sqlite.BulkInsertRomCrcs(db, romCrs)
-	sqlite.BulkInsertImageMap(db, "Screenshot", screenshotMap, blobHashMap, corePath)
-	sqlite.BulkInsertImageMap(db, "TitleScreen", titleScreenMap, blobHashMap, corePath)
+	sqlite.BulkInsertRomTags(db, romTags)
+	result.Games = len(reindexedGames) - 1
+
+	imageRefs := []sqlite.ImageRef{}
+	for gameID := range reindexedGames {
+		if path := screenshotMap[gameID]; path != "" {
+			imageRefs = append(imageRefs, sqlite.ImageRef{GameID: gameID, Column: sqlite.ScreenshotColumn, Path: path})
+		}
+		if path := titleScreenMap[gameID]; path != "" {
+			imageRefs = append(imageRefs, sqlite.ImageRef{GameID: gameID, Column: sqlite.TitleScreenColumn, Path: path})
+		}
+	}
+	fmt.Printf("Adding %v images with %v workers\n", len(imageRefs), imageOptions.Workers)
+	result.Images, err = sqlite.InsertImages(db, imageRefs, corePath, imageOptions)
+	if err != nil {
+		result.Err = fmt.Errorf("images: %w", err)
+		return result
+	}
+	if result.Images.Recompress > 0 {
+		fmt.Printf("Recompressed %v PNGs, saved %v bytes\n", result.Images.Recompress, result.Images.SavedBytes)
+	}
 	fmt.Println("MGDB Built Successfully")
 	sqlite.Vacuum(db)
-	db.Close()
+
+	report.print()
+	if err := report.write(filepath.Join(corePath, "buildreport.json")); err != nil {
+		fmt.Println("Unable to write buildreport.json", err)
+	}
+	if err := collision.WriteJSON(filepath.Join(corePath, collision.BuildReportFile), report.Collisions); err != nil {
+		fmt.Println("Unable to write", collision.BuildReportFile, err)
+	}
+	result.Collisions = collision.Unresolved(report.Collisions)
+	return result
+}
+
+// buildReport summarizes curation decisions made during a build,
+// written next to the MGDB as buildreport.json
+type buildReport struct {
+	CollectionName   string                `json:"collection_name"`
+	OverridesApplied []overrides.Applied   `json:"overrides_applied"`
+	Collisions       []collision.Collision `json:"collisions"`
+}
+
+func (report *buildReport) print() {
+	overrides.SortApplied(report.OverridesApplied)
+	fmt.Printf("Build report %v\n", report.CollectionName)
+	fmt.Printf("Overrides applied: %v\n", len(report.OverridesApplied))
+	for _, applied := range report.OverridesApplied {
+		fmt.Printf("  %v\n", applied)
+	}
+	collision.Print(report.Collisions)
+}
+
+func (report *buildReport) write(path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/config"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mister"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+func main() {
+	coresPath := flag.String("cores", "", "user core override file, see mister.CoreOverrides")
+	launchPath := flag.String("launch", "", "launch this indexed game file on the MiSTer instead of writing MGLs")
+	flag.Parse()
+
+	fmt.Println(os.Args)
+	cliArgs := flag.Args()
+	if len(cliArgs) < 2 && !(*launchPath != "" && len(cliArgs) == 1) {
+		fmt.Println("Usage: buildmgl [--cores cores.json] {path.mgdb} {outFolder}")
+		fmt.Println("       buildmgl [--cores cores.json] --launch {gamePath} {path.mgdb}")
+		return
+	}
+	coreOverrides, err := mister.LoadCoreOverrides(*coresPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *launchPath != "" {
+		err = launch(cliArgs[0], *launchPath, coreOverrides)
+	} else {
+		err = buildMGLs(cliArgs[0], cliArgs[1], coreOverrides)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// readIndexed loads the MiSTer systems and indexed ROMs of an MGDB
+func readIndexed(mgdbPath string) ([]mister.System, []mgdb.IndexedRom, error) {
+	db, err := sqlite.OpenMGDB(mgdbPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open MGDB %v: %w", mgdbPath, err)
+	}
+	defer db.Close()
+
+	info, err := sqlite.GetMGDBInfo(db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read MGDBInfo: %w", err)
+	}
+	systems := config.SystemsForMGDB(info.GamesFolder, info.SupportedSystemIds)
+	if len(systems) == 0 {
+		return nil, nil, fmt.Errorf("no MiSTer systems for %v", info.CollectionName)
+	}
+
+	roms, err := sqlite.GetIndexedRoms(db)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read IndexedRom: %w", err)
+	}
+	return systems, roms, nil
+}
+
+func buildMGLs(mgdbPath string, outPath string, coreOverrides mister.CoreOverrides) error {
+	systems, roms, err := readIndexed(mgdbPath)
+	if err != nil {
+		return err
+	}
+	if len(roms) == 0 {
+		fmt.Println("No IndexedRom entries, index the MGDB first")
+		return nil
+	}
+
+	err = os.MkdirAll(outPath, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("unable to create path %v: %w", outPath, err)
+	}
+
+	written := make(map[string]bool)
+	for _, rom := range roms {
+		system, mglDef, err := mister.GroupMglDef(romSystems(systems, rom), rom.Path)
+		if err != nil {
+			fmt.Println("Skipping", err)
+			continue
+		}
+
+		contents, err := mister.MglContents(coreOverrides.Rbf(system, rom.Path), mglDef, rom.Path)
+		if err != nil {
+			fmt.Println("Skipping", err)
+			continue
+		}
+		mglPath := filepath.Join(outPath, mglName(rom, written)+".mgl")
+		if err := os.WriteFile(mglPath, []byte(contents), 0644); err != nil {
+			fmt.Printf("Unable to write file %s\n", mglPath)
+			continue
+		}
+		fmt.Printf("Created MGL %s\n", mglPath)
+	}
+	return nil
+}
+
+// launch starts one game with the same core choice as its MGL would use
+func launch(mgdbPath string, gamePath string, coreOverrides mister.CoreOverrides) error {
+	systems, roms, err := readIndexed(mgdbPath)
+	if err != nil {
+		return err
+	}
+	launchSystems := systems
+	for _, rom := range roms {
+		if rom.Path == gamePath {
+			launchSystems = romSystems(systems, rom)
+			break
+		}
+	}
+	if err := mister.LaunchGame(launchSystems, gamePath, coreOverrides); err != nil {
+		return err
+	}
+	fmt.Println("Launched", gamePath)
+	return nil
+}
+
+// Systems a ROM was indexed under take precedence over the rest of the CoreGroup
+func romSystems(systems []mister.System, rom mgdb.IndexedRom) []mister.System {
+	if rom.SupportedSystemIds == "" {
+		return systems
+	}
+	ids := "," + rom.SupportedSystemIds + ","
+	preferred := []mister.System{}
+	rest := []mister.System{}
+	for _, system := range systems {
+		if system.Id != "" && strings.Contains(ids, ","+system.Id+",") {
+			preferred = append(preferred, system)
+		} else {
+			rest = append(rest, system)
+		}
+	}
+	return append(preferred, rest...)
+}
+
+// Unique MGL filename per ROM, same names with different extensions get the
+// extension appended, then a counter until the name is free
+func mglName(rom mgdb.IndexedRom, written map[string]bool) string {
+	base := rom.FileName
+	if base == "" {
+		base = strings.TrimSuffix(filepath.Base(rom.Path), filepath.Ext(rom.Path))
+	}
+	base = strings.NewReplacer("/", "-", "\\", "-").Replace(base)
+	name := base
+	if written[strings.ToLower(name)] {
+		base = fmt.Sprintf("%v (%v)", base, strings.TrimPrefix(rom.FileExt, "."))
+		name = base
+	}
+	for i := 2; written[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%v (%v)", base, i)
+	}
+	written[strings.ToLower(name)] = true
+	return name
+}
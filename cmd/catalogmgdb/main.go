@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/export"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+func main() {
+	cliArgs := os.Args
+	fmt.Println(cliArgs)
+	if len(cliArgs) < 3 {
+		fmt.Println("Usage: catalogmgdb {outFolder} {path.mgdb || folder}...")
+		return
+	}
+
+	count, err := catalogMGDBs(cliArgs[1], cliArgs[2:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Cataloged %v games to %v\n", count, cliArgs[1])
+}
+
+func catalogMGDBs(outDir string, args []string) (int, error) {
+	paths, err := sqlite.FindMGDBs(args)
+	if err != nil {
+		return 0, err
+	}
+
+	collections := []*export.Collection{}
+	for _, path := range paths {
+		db, err := sqlite.OpenMGDB(path)
+		if err != nil {
+			return 0, fmt.Errorf("unable to open %v: %w", path, err)
+		}
+		defer db.Close()
+
+		collection, err := export.Load(db)
+		if err != nil {
+			return 0, fmt.Errorf("unable to read %v: %w", path, err)
+		}
+		fmt.Printf("Loaded %v games from %v\n", len(collection.Games), path)
+		collections = append(collections, collection)
+	}
+	return export.WriteCatalog(collections, outDir)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/config"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/rdb"
+)
+
+// Upstream change of one DataConfig's RDB
+type systemChange struct {
+	System  string `json:"system"`
+	RdbName string `json:"rdbName"`
+	Status  string `json:"status"`
+}
+
+type changeReport struct {
+	Pinned    string         `json:"pinned"`
+	Head      string         `json:"head"`
+	HeadRef   string         `json:"headRef"`
+	AheadBy   int            `json:"aheadBy"`
+	Truncated bool           `json:"truncated,omitempty"`
+	Changes   []systemChange `json:"changes"`
+}
+
+func main() {
+	manifestPath := flag.String("manifest", filepath.Join(config.CommandRootPath, "rdbmanifest.json"), "RDB lock file with the pinned revision")
+	head := flag.String("head", "master", "libretro-database ref to compare the pin against")
+	jsonOut := flag.Bool("json", false, "print the report as JSON")
+	failOnChange := flag.Bool("fail-on-change", false, "exit non-zero when any system has upstream changes")
+	flag.Parse()
+
+	report, err := checkRDB(*manifestPath, *head)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if *jsonOut {
+		data, _ := json.MarshalIndent(report, "", "\t")
+		fmt.Println(string(data))
+	} else {
+		printReport(report)
+	}
+	if *failOnChange && len(report.Changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Compares the pinned libretro-database revision to head, per DataConfig
+func checkRDB(manifestPath string, head string) (changeReport, error) {
+	report := changeReport{HeadRef: head, Changes: []systemChange{}}
+	manifest, err := rdb.LoadManifest(manifestPath)
+	if err != nil {
+		return report, err
+	}
+	if manifest.Revision == "" {
+		return report, fmt.Errorf("no pinned revision in %v, run setuprdb --ref {ref} --write-manifest", manifestPath)
+	}
+	report.Pinned = manifest.Revision
+
+	// Bounded, a stalled GitHub API call would hang scheduled checks
+	client := &http.Client{Timeout: time.Minute}
+	report.Head, err = rdb.ResolveRevision(client, head)
+	if err != nil {
+		return report, err
+	}
+	if report.Head == report.Pinned {
+		return report, nil
+	}
+
+	comparison, err := rdb.CompareRevisions(client, report.Pinned, report.Head)
+	if err != nil {
+		return report, err
+	}
+	report.AheadBy = comparison.AheadBy
+	report.Truncated = comparison.Truncated
+
+	for system, dataConfig := range config.DataConfigs {
+		if status, ok := comparison.Changed[dataConfig.RdbName]; ok {
+			report.Changes = append(report.Changes, systemChange{System: system, RdbName: dataConfig.RdbName, Status: status})
+		}
+	}
+	sort.Slice(report.Changes, func(i, j int) bool {
+		return report.Changes[i].System < report.Changes[j].System
+	})
+	return report, nil
+}
+
+func printReport(report changeReport) {
+	fmt.Printf("Pinned %v, %v at %v (%v commits ahead)\n", report.Pinned, report.HeadRef, report.Head, report.AheadBy)
+	if report.Truncated {
+		fmt.Println("GitHub truncated the file list, changes may be incomplete")
+	}
+	if len(report.Changes) == 0 {
+		fmt.Println("No system RDBs changed upstream")
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SYSTEM\tSTATUS\tRDB")
+	for _, change := range report.Changes {
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", change.System, change.Status, change.RdbName)
+	}
+	writer.Flush()
+	fmt.Printf("%v systems changed upstream\n", len(report.Changes))
+}
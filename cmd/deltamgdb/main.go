@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/delta"
+)
+
+func main() {
+	cliArgs := os.Args
+	fmt.Println(cliArgs)
+	if len(cliArgs) != 4 {
+		fmt.Println("Usage: deltamgdb {old.mgdb} {new.mgdb} {out.mgdbpatch}")
+		return
+	}
+
+	summary, err := delta.Create(cliArgs[1], cliArgs[2], cliArgs[3])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	printSummary(summary)
+
+	stat, err := os.Stat(cliArgs[3])
+	if err == nil {
+		fmt.Printf("Wrote %v, %v bytes\n", cliArgs[3], stat.Size())
+	}
+}
+
+func printSummary(summary *delta.Summary) {
+	info := summary.Info
+	fmt.Printf("%v -> %v\n", info.BaseName, info.ResultName)
+	tables := []string{}
+	seen := make(map[string]bool)
+	for _, counts := range []map[string]int{summary.Deletes, summary.Upserts} {
+		for table := range counts {
+			if !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+		}
+	}
+	sort.Strings(tables)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TABLE\tDELETES\tUPSERTS")
+	for _, table := range tables {
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", table, summary.Deletes[table], summary.Upserts[table])
+	}
+	writer.Flush()
+	fmt.Printf("%v row changes, %v new images (%v bytes)\n", summary.Rows, summary.Blobs, summary.BlobBytes)
+}
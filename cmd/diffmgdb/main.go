@@ -0,0 +1,434 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+// A game on one side of the diff, slugs from SlugRom
+type diffGame struct {
+	sqlite.GameRow
+	Slugs []string
+}
+
+type gameRef struct {
+	Name       string `json:"name"`
+	ExternalID string `json:"externalId,omitempty"`
+}
+
+type fieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type gameChange struct {
+	Name       string        `json:"name"`
+	ExternalID string        `json:"externalId,omitempty"`
+	MatchedBy  string        `json:"matchedBy"` // externalId or slug
+	Fields     []fieldChange `json:"fields,omitempty"`
+	Images     []fieldChange `json:"images,omitempty"` // ImageBlob hashes
+}
+
+type slugChange struct {
+	Slug string `json:"slug"`
+	Old  string `json:"old,omitempty"` // game name, empty when added
+	New  string `json:"new,omitempty"` // game name, empty when removed
+}
+
+type crcChange struct {
+	CRC32 string `json:"crc32"`
+	Old   string `json:"old,omitempty"` // slug, empty when added
+	New   string `json:"new,omitempty"` // slug, empty when removed
+}
+
+type mappingCounts struct {
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Remapped int `json:"remapped"`
+}
+
+type diffReport struct {
+	Old      mgdb.MGDBInfo `json:"old"`
+	New      mgdb.MGDBInfo `json:"new"`
+	OldGames int           `json:"oldGames"`
+	NewGames int           `json:"newGames"`
+	Added    []gameRef     `json:"added"`
+	Removed  []gameRef     `json:"removed"`
+	Changed  []gameChange  `json:"changed"`
+	Images   mappingCounts `json:"images"`
+	Slugs    mappingCounts `json:"slugs"`
+	Crcs     mappingCounts `json:"crcs"`
+	SlugDiff []slugChange  `json:"slugChanges"`
+	CrcDiff  []crcChange   `json:"crcChanges"`
+}
+
+func main() {
+	jsonOut := flag.Bool("json", false, "print the diff as JSON")
+	details := flag.Bool("details", false, "print old and new values and every slug and CRC change")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Println("Usage: diffmgdb [--json] [--details] {old.mgdb} {new.mgdb}")
+		return
+	}
+
+	report, err := diffMGDBs(flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *jsonOut {
+		data, _ := json.MarshalIndent(report, "", "\t")
+		fmt.Println(string(data))
+		return
+	}
+	printReport(report, *details)
+}
+
+// Side of a diff: games by GameID, slug:GameID and crc:slug
+type diffSide struct {
+	info  mgdb.MGDBInfo
+	games map[int]*diffGame
+	slugs map[string]int
+	crcs  map[string]string
+}
+
+func loadSide(path string) (*diffSide, error) {
+	db, err := sqlite.OpenMGDB(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %v: %w", path, err)
+	}
+	defer db.Close()
+	// Slugs and CRCs repeat across a merged MGDB's collections
+	if merged, err := sqlite.IsMerged(db); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %w", path, err)
+	} else if merged {
+		return nil, fmt.Errorf("%v is a merged MGDB, diff its per system MGDBs instead", path)
+	}
+	side, err := readSide(db)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %v: %w", path, err)
+	}
+	return side, nil
+}
+
+func readSide(db *sql.DB) (*diffSide, error) {
+	side := &diffSide{
+		games: make(map[int]*diffGame),
+		slugs: make(map[string]int),
+		crcs:  make(map[string]string),
+	}
+	var err error
+	side.info, err = sqlite.GetMGDBInfo(db)
+	if err != nil {
+		return side, err
+	}
+	rows, _, err := sqlite.QueryGames(db, sqlite.GameFilter{})
+	if err != nil {
+		return side, err
+	}
+	for _, row := range rows {
+		side.games[row.GameID] = &diffGame{GameRow: row}
+	}
+	slugRoms, err := sqlite.GetSlugRoms(db)
+	if err != nil {
+		return side, err
+	}
+	for _, slugRom := range slugRoms {
+		side.slugs[slugRom.Slug] = slugRom.GameID
+		if game, ok := side.games[slugRom.GameID]; ok {
+			game.Slugs = append(game.Slugs, slugRom.Slug)
+		}
+	}
+	romCrcs, err := sqlite.GetRomCrcs(db)
+	if err != nil {
+		return side, err
+	}
+	for _, romCrc := range romCrcs {
+		side.crcs[strings.ToLower(romCrc.CRC32)] = romCrc.Slug
+	}
+	return side, nil
+}
+
+// Pairs old GameIDs with new ones, first by ExternalID then by a shared slug.
+// Returns old:new GameIDs and how each pair was matched.
+func matchGames(oldSide *diffSide, newSide *diffSide) (map[int]int, map[int]string) {
+	matches := make(map[int]int)
+	matchedBy := make(map[int]string)
+	matchedNew := make(map[int]bool)
+
+	newByExternalID := make(map[string]int)
+	for gameID, game := range newSide.games {
+		if game.ExternalID != "" {
+			newByExternalID[game.ExternalID] = gameID
+		}
+	}
+	for _, gameID := range sortedGameIDs(oldSide.games) {
+		game := oldSide.games[gameID]
+		if newID, ok := newByExternalID[game.ExternalID]; ok && game.ExternalID != "" && !matchedNew[newID] {
+			matches[gameID] = newID
+			matchedBy[gameID] = "externalId"
+			matchedNew[newID] = true
+		}
+	}
+
+	for _, gameID := range sortedGameIDs(oldSide.games) {
+		if _, ok := matches[gameID]; ok {
+			continue
+		}
+		for _, slug := range oldSide.games[gameID].Slugs {
+			newID, ok := newSide.slugs[slug]
+			if !ok || matchedNew[newID] || newSide.games[newID] == nil {
+				continue
+			}
+			matches[gameID] = newID
+			matchedBy[gameID] = "slug"
+			matchedNew[newID] = true
+			break
+		}
+	}
+	return matches, matchedBy
+}
+
+func sortedGameIDs(games map[int]*diffGame) []int {
+	ids := make([]int, 0, len(games))
+	for gameID := range games {
+		ids = append(ids, gameID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func diffFields(oldGame *diffGame, newGame *diffGame) ([]fieldChange, []fieldChange) {
+	fields := []fieldChange{}
+	for _, field := range []fieldChange{
+		{"Name", oldGame.Name, newGame.Name},
+		{"ExternalID", oldGame.ExternalID, newGame.ExternalID},
+		{"Genre", oldGame.Genre, newGame.Genre},
+		{"Developer", oldGame.Developer, newGame.Developer},
+		{"Publisher", oldGame.Publisher, newGame.Publisher},
+		{"ReleaseDate", oldGame.ReleaseDate, newGame.ReleaseDate},
+		{"Rating", oldGame.Rating, newGame.Rating},
+		{"Players", oldGame.Players, newGame.Players},
+		{"Description", oldGame.Description, newGame.Description},
+	} {
+		if field.Old != field.New {
+			fields = append(fields, field)
+		}
+	}
+	images := []fieldChange{}
+	for _, image := range []fieldChange{
+		{"Screenshot", oldGame.ScreenshotHash, newGame.ScreenshotHash},
+		{"TitleScreen", oldGame.TitleScreenHash, newGame.TitleScreenHash},
+	} {
+		if image.Old != image.New {
+			images = append(images, image)
+		}
+	}
+	return fields, images
+}
+
+func gameName(side *diffSide, gameID int) string {
+	if game, ok := side.games[gameID]; ok {
+		return game.Name
+	}
+	return fmt.Sprintf("GameID %v", gameID)
+}
+
+func diffMGDBs(oldPath string, newPath string) (diffReport, error) {
+	report := diffReport{
+		Added:    []gameRef{},
+		Removed:  []gameRef{},
+		Changed:  []gameChange{},
+		SlugDiff: []slugChange{},
+		CrcDiff:  []crcChange{},
+	}
+	oldSide, err := loadSide(oldPath)
+	if err != nil {
+		return report, err
+	}
+	newSide, err := loadSide(newPath)
+	if err != nil {
+		return report, err
+	}
+	report.Old = oldSide.info
+	report.New = newSide.info
+	report.OldGames = len(oldSide.games)
+	report.NewGames = len(newSide.games)
+
+	matches, matchedBy := matchGames(oldSide, newSide)
+	// Loose ROMs stay on GameID 0 in both builds
+	matches[0] = 0
+	matchedNew := make(map[int]int)
+	for oldID, newID := range matches {
+		matchedNew[newID] = oldID
+	}
+
+	for _, gameID := range sortedGameIDs(oldSide.games) {
+		oldGame := oldSide.games[gameID]
+		newID, ok := matches[gameID]
+		if !ok {
+			report.Removed = append(report.Removed, gameRef{Name: oldGame.Name, ExternalID: oldGame.ExternalID})
+			continue
+		}
+		fields, images := diffFields(oldGame, newSide.games[newID])
+		if len(fields) == 0 && len(images) == 0 {
+			continue
+		}
+		for _, image := range images {
+			switch {
+			case image.Old == "":
+				report.Images.Added++
+			case image.New == "":
+				report.Images.Removed++
+			default:
+				report.Images.Remapped++
+			}
+		}
+		report.Changed = append(report.Changed, gameChange{
+			Name:       newSide.games[newID].Name,
+			ExternalID: newSide.games[newID].ExternalID,
+			MatchedBy:  matchedBy[gameID],
+			Fields:     fields,
+			Images:     images,
+		})
+	}
+	for _, gameID := range sortedGameIDs(newSide.games) {
+		if _, ok := matchedNew[gameID]; !ok {
+			newGame := newSide.games[gameID]
+			report.Added = append(report.Added, gameRef{Name: newGame.Name, ExternalID: newGame.ExternalID})
+		}
+	}
+	sort.Slice(report.Added, func(i, j int) bool {
+		return strings.ToLower(report.Added[i].Name) < strings.ToLower(report.Added[j].Name)
+	})
+	sort.Slice(report.Removed, func(i, j int) bool {
+		return strings.ToLower(report.Removed[i].Name) < strings.ToLower(report.Removed[j].Name)
+	})
+	sort.Slice(report.Changed, func(i, j int) bool {
+		return strings.ToLower(report.Changed[i].Name) < strings.ToLower(report.Changed[j].Name)
+	})
+
+	// A slug is remapped when it moves to a game other than its old game's match
+	for slug, oldID := range oldSide.slugs {
+		newID, ok := newSide.slugs[slug]
+		if !ok {
+			report.SlugDiff = append(report.SlugDiff, slugChange{Slug: slug, Old: gameName(oldSide, oldID)})
+			report.Slugs.Removed++
+			continue
+		}
+		if matched, ok := matches[oldID]; !ok || matched != newID {
+			report.SlugDiff = append(report.SlugDiff, slugChange{
+				Slug: slug, Old: gameName(oldSide, oldID), New: gameName(newSide, newID),
+			})
+			report.Slugs.Remapped++
+		}
+	}
+	for slug, newID := range newSide.slugs {
+		if _, ok := oldSide.slugs[slug]; !ok {
+			report.SlugDiff = append(report.SlugDiff, slugChange{Slug: slug, New: gameName(newSide, newID)})
+			report.Slugs.Added++
+		}
+	}
+	sort.Slice(report.SlugDiff, func(i, j int) bool {
+		return report.SlugDiff[i].Slug < report.SlugDiff[j].Slug
+	})
+
+	for crc, oldSlug := range oldSide.crcs {
+		newSlug, ok := newSide.crcs[crc]
+		if !ok {
+			report.CrcDiff = append(report.CrcDiff, crcChange{CRC32: crc, Old: oldSlug})
+			report.Crcs.Removed++
+		} else if newSlug != oldSlug {
+			report.CrcDiff = append(report.CrcDiff, crcChange{CRC32: crc, Old: oldSlug, New: newSlug})
+			report.Crcs.Remapped++
+		}
+	}
+	for crc, newSlug := range newSide.crcs {
+		if _, ok := oldSide.crcs[crc]; !ok {
+			report.CrcDiff = append(report.CrcDiff, crcChange{CRC32: crc, New: newSlug})
+			report.Crcs.Added++
+		}
+	}
+	sort.Slice(report.CrcDiff, func(i, j int) bool {
+		return report.CrcDiff[i].CRC32 < report.CrcDiff[j].CRC32
+	})
+	return report, nil
+}
+
+// Shortens long values such as descriptions to one line
+func clip(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if runes := []rune(value); len(runes) > 60 {
+		return string(runes[:57]) + "..."
+	}
+	if value == "" {
+		return "(empty)"
+	}
+	return value
+}
+
+func fieldNames(changes []fieldChange) []string {
+	names := []string{}
+	for _, change := range changes {
+		names = append(names, change.Field)
+	}
+	return names
+}
+
+func printReport(report diffReport, details bool) {
+	fmt.Printf("%v (%v) -> %v (%v)\n", report.Old.CollectionName, report.Old.BuildDate, report.New.CollectionName, report.New.BuildDate)
+	fmt.Printf("Games: %v -> %v, %v added, %v removed, %v changed\n",
+		report.OldGames, report.NewGames, len(report.Added), len(report.Removed), len(report.Changed))
+	fmt.Printf("Images: %v added, %v removed, %v replaced\n", report.Images.Added, report.Images.Removed, report.Images.Remapped)
+	fmt.Printf("Slugs: %v added, %v removed, %v remapped\n", report.Slugs.Added, report.Slugs.Removed, report.Slugs.Remapped)
+	fmt.Printf("CRCs: %v added, %v removed, %v remapped\n", report.Crcs.Added, report.Crcs.Removed, report.Crcs.Remapped)
+
+	if len(report.Added) > 0 {
+		fmt.Println("\nAdded")
+		for _, game := range report.Added {
+			fmt.Printf("- %v\n", game.Name)
+		}
+	}
+	if len(report.Removed) > 0 {
+		fmt.Println("\nRemoved")
+		for _, game := range report.Removed {
+			fmt.Printf("- %v\n", game.Name)
+		}
+	}
+	if len(report.Changed) > 0 {
+		fmt.Println("\nChanged")
+		for _, game := range report.Changed {
+			fmt.Printf("- %v: %v\n", game.Name, strings.Join(append(fieldNames(game.Fields), fieldNames(game.Images)...), ", "))
+			if !details {
+				continue
+			}
+			for _, change := range append(game.Fields, game.Images...) {
+				fmt.Printf("    %v: %v -> %v\n", change.Field, clip(change.Old), clip(change.New))
+			}
+		}
+	}
+	if !details {
+		return
+	}
+	if len(report.SlugDiff) > 0 {
+		fmt.Println("\nSlugs")
+		for _, change := range report.SlugDiff {
+			fmt.Printf("- %v: %v -> %v\n", change.Slug, clip(change.Old), clip(change.New))
+		}
+	}
+	if len(report.CrcDiff) > 0 {
+		fmt.Println("\nCRCs")
+		for _, change := range report.CrcDiff {
+			fmt.Printf("- %v: %v -> %v\n", change.CRC32, clip(change.Old), clip(change.New))
+		}
+	}
+}
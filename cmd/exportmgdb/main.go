@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/export"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+var formats = map[string]func(*export.Collection, string) (int, error){
+	"gamelist":    export.WriteGamelist,
+	"pegasus":     export.WritePegasus,
+	"attractmode": export.WriteAttractMode,
+}
+
+func main() {
+	cliArgs := os.Args
+	fmt.Println(cliArgs)
+	if len(cliArgs) < 4 {
+		fmt.Println("Usage: exportmgdb {gamelist|pegasus|attractmode} {path.mgdb} {outFolder}")
+		return
+	}
+	writer, ok := formats[cliArgs[1]]
+	if !ok {
+		fmt.Println("Invalid export format", cliArgs[1])
+		return
+	}
+
+	db, err := sqlite.OpenMGDB(cliArgs[2])
+	if err != nil {
+		fmt.Println("Unable to open MGDB", cliArgs[2], err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	collection, err := export.Load(db)
+	if err != nil {
+		fmt.Println("Unable to read MGDB", err)
+		os.Exit(1)
+	}
+	count, err := writer(collection, cliArgs[3])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Exported %v entries to %v\n", count, cliArgs[3])
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+func main() {
+	fmt.Println(os.Args)
+	apply := flag.Bool("apply", false, "delete unused rows and vacuum, the default is a dry run")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: gcmgdb [--apply] {path.mgdb || folder}...")
+		return
+	}
+
+	paths, err := sqlite.FindMGDBs(flag.Args())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for _, path := range paths {
+		report, err := sqlite.CollectGarbage(path, *apply)
+		if err != nil {
+			fmt.Printf("Unable to collect %v: %v\n", path, err)
+			os.Exit(1)
+		}
+		printReport(report)
+	}
+	if !*apply {
+		fmt.Println("Dry run, rerun with --apply to delete unused rows")
+	}
+}
+
+func printReport(report sqlite.GCReport) {
+	fmt.Println(report.Path)
+	if report.MediaPath != "" {
+		fmt.Println("Media pack", report.MediaPath)
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TABLE\tROWS\tUNUSED\tBYTES")
+	for _, table := range report.Tables {
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", table.Table, table.Rows, table.Unused, table.Bytes)
+	}
+	writer.Flush()
+
+	if report.Applied {
+		fmt.Printf("Removed %v rows, %v -> %v bytes (saved %v)\n",
+			report.UnusedRows(), report.SizeBefore, report.SizeAfter, report.SizeBefore-report.SizeAfter)
+		return
+	}
+	fmt.Printf("%v unused rows, about %v of %v bytes reclaimable\n",
+		report.UnusedRows(), report.UnusedBytes(), report.SizeBefore)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/config"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/indexer"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mister"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/overrides"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+// Repeatable string flag
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+func main() {
+	var roots stringList
+	flag.Var(&roots, "root", "games root to scan, repeatable (default: MiSTer fat, usb and cifs games folders)")
+	overridesPath := flag.String("overrides", "", "overrides.json pinning files to gamelist game IDs (default: overrides.json next to the MGDB)")
+	flag.Parse()
+
+	fmt.Println(os.Args)
+	cliArgs := flag.Args()
+	if len(cliArgs) < 1 {
+		fmt.Println("Usage: indexmgdb [--root dir]... [--overrides overrides.json] {path.mgdb}")
+		return
+	}
+	if len(roots) == 0 {
+		roots = mister.GamesRoots(mister.GamesRootCandidates)
+	}
+
+	if err := indexMGDB(cliArgs[0], roots, *overridesPath); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func indexMGDB(mgdbPath string, roots []string, overridesPath string) error {
+	var ovr *overrides.Overrides
+	if overridesPath != "" {
+		loaded, err := overrides.LoadFile(overridesPath)
+		if err != nil {
+			return err
+		}
+		ovr = loaded
+	}
+
+	db, err := sqlite.OpenMGDBForUpdate(mgdbPath)
+	if err != nil {
+		return fmt.Errorf("unable to open MGDB %v: %w", mgdbPath, err)
+	}
+	defer db.Close()
+
+	info, err := sqlite.GetMGDBInfo(db)
+	if err != nil {
+		return fmt.Errorf("unable to read MGDBInfo: %w", err)
+	}
+	merged, err := sqlite.IsMerged(db)
+	if err != nil {
+		return err
+	}
+
+	// Merged MGDBs are indexed per collection, slugs and CRCs only
+	// identify a game within one system
+	targets := []sqlite.MergedCollection{{
+		CollectionID:       -1,
+		CollectionName:     info.CollectionName,
+		GamesFolder:        info.GamesFolder,
+		SupportedSystemIds: info.SupportedSystemIds,
+	}}
+	if merged {
+		if targets, err = sqlite.GetMergedCollections(db); err != nil {
+			return fmt.Errorf("unable to read Collection: %w", err)
+		}
+	}
+
+	roms := []mgdb.IndexedRom{}
+	seen := make(map[string]bool) // [path]indexed
+	applied := []overrides.Applied{}
+	for _, target := range targets {
+		systems := config.SystemsForMGDB(target.GamesFolder, target.SupportedSystemIds)
+		dataConfig, _ := config.DataConfigForFolder(target.GamesFolder)
+
+		fmt.Printf("Scanning %v for %v\n", strings.Join(roots, ", "), target.CollectionName)
+		files, err := mister.Scan(roots, systems)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Found %v game files\n", len(files))
+
+		// The same pins buildmgdb applied, copied next to the MGDB
+		targetOvr := ovr
+		if targetOvr == nil {
+			if targetOvr, err = loadOverrides(mgdbPath, target.GamesFolder, merged); err != nil {
+				return err
+			}
+		}
+
+		var idx *indexer.Indexer
+		if merged {
+			idx, err = indexer.NewForCollection(db, target.CollectionID, dataConfig.Naming, targetOvr)
+		} else {
+			idx, err = indexer.New(db, dataConfig.Naming, targetOvr)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to load MGDB mappings: %w", err)
+		}
+		for _, rom := range idx.Index(files) {
+			if !seen[rom.Path] {
+				seen[rom.Path] = true
+				roms = append(roms, rom)
+			}
+		}
+		applied = append(applied, idx.Applied...)
+	}
+	sqlite.ReplaceIndexedRoms(db, roms)
+
+	matched := 0
+	for _, rom := range roms {
+		if rom.GameID != 0 {
+			matched++
+		}
+	}
+	fmt.Printf("Indexed %v files, %v matched, %v loose\n", len(roms), matched, len(roms)-matched)
+	overrides.SortApplied(applied)
+	fmt.Printf("Overrides applied: %v\n", len(applied))
+	for _, entry := range applied {
+		fmt.Printf("  %v\n", entry)
+	}
+	return nil
+}
+
+// loadOverrides finds a collection's overrides.json next to the MGDB, in a
+// {GamesFolder} folder, or beside it when the MGDB holds one collection
+func loadOverrides(mgdbPath string, gamesFolder string, merged bool) (*overrides.Overrides, error) {
+	dir := filepath.Dir(mgdbPath)
+	candidates := []string{filepath.Join(dir, gamesFolder, "overrides.json")}
+	if !merged {
+		candidates = append(candidates, filepath.Join(dir, "overrides.json"))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			fmt.Println("Using overrides", path)
+			return overrides.LoadFile(path)
+		}
+	}
+	fmt.Printf("No overrides found for %v, looked for %v\n", gamesFolder, strings.Join(candidates, ", "))
+	return overrides.New(), nil
+}
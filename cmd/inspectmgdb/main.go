@@ -0,0 +1,375 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/rdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+type tableCount struct {
+	Table string `json:"table"`
+	Rows  int    `json:"rows"`
+}
+
+type coverage struct {
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+type gameCoverage struct {
+	Games        int      `json:"games"`
+	Indexed      coverage `json:"indexed"`
+	Screenshots  coverage `json:"screenshots"`
+	TitleScreens coverage `json:"titleScreens"`
+	Descriptions coverage `json:"descriptions"`
+	ReleaseDates coverage `json:"releaseDates"`
+	Developers   coverage `json:"developers"`
+	Publishers   coverage `json:"publishers"`
+	Genres       coverage `json:"genres"`
+}
+
+// RDB ROMs from the rdb.ndjson the MGDB was built from, by CRC.
+// Skipped ROMs had no slug match in the gamelist source.
+type crcCoverage struct {
+	RdbPath  string   `json:"rdbPath"`
+	RdbRoms  int      `json:"rdbRoms"`
+	Mapped   coverage `json:"mapped"`
+	Skipped  int      `json:"skipped"`
+	NoCrc    int      `json:"noCrc"`
+	Unknown  int      `json:"unknown"` // RomCrc rows missing from the RDB, built from another revision
+	Examples []string `json:"skippedExamples,omitempty"`
+}
+
+type genreCount struct {
+	Name    string  `json:"name"`
+	Games   int     `json:"games"`
+	Percent float64 `json:"percent"`
+}
+
+type sizeBucket struct {
+	Label string `json:"label"`
+	Max   int64  `json:"max,omitempty"` // exclusive upper bound, 0 for the last bucket
+	Count int    `json:"count"`
+	Bytes int64  `json:"bytes"`
+}
+
+type imageStats struct {
+	Blobs   int          `json:"blobs"`
+	Bytes   int64        `json:"bytes"`
+	Min     int64        `json:"min"`
+	Median  int64        `json:"median"`
+	P90     int64        `json:"p90"`
+	Max     int64        `json:"max"`
+	Buckets []sizeBucket `json:"buckets"`
+}
+
+type inspectReport struct {
+	Path      string        `json:"path"`
+	FileSize  int64         `json:"fileSize"`
+	Info      mgdb.MGDBInfo `json:"info"`
+	Tables    []tableCount  `json:"tables"`
+	Coverage  gameCoverage  `json:"coverage"`
+	Crc       *crcCoverage  `json:"crc,omitempty"`
+	TopGenres []genreCount  `json:"topGenres"`
+	Images    imageStats    `json:"images"`
+}
+
+var sizeBuckets = []sizeBucket{
+	{Label: "<16KiB", Max: 16 << 10},
+	{Label: "16-64KiB", Max: 64 << 10},
+	{Label: "64-256KiB", Max: 256 << 10},
+	{Label: "256KiB-1MiB", Max: 1 << 20},
+	{Label: ">=1MiB"},
+}
+
+func main() {
+	jsonOut := flag.Bool("json", false, "print the reports as JSON")
+	rdbPath := flag.String("rdb", "", "rdb.ndjson for CRC coverage, defaults to the one next to each MGDB")
+	topGenres := flag.Int("genres", 10, "number of top genres to list, 0 for all")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: inspectmgdb [--json] [--rdb rdb.ndjson] [--genres 10] {path.mgdb || folder}...")
+		return
+	}
+
+	paths, err := sqlite.FindMGDBs(flag.Args())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	reports := []inspectReport{}
+	for _, path := range paths {
+		report, err := inspectMGDB(path, *rdbPath, *topGenres)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to inspect %v: %v\n", path, err)
+			os.Exit(1)
+		}
+		reports = append(reports, report)
+	}
+
+	if *jsonOut {
+		data, _ := json.MarshalIndent(reports, "", "\t")
+		fmt.Println(string(data))
+		return
+	}
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		printReport(report)
+	}
+}
+
+func percent(count int, total int) coverage {
+	if total == 0 {
+		return coverage{Count: count}
+	}
+	return coverage{Count: count, Percent: math.Round(float64(count)*10000/float64(total)) / 100}
+}
+
+func inspectMGDB(path string, rdbPath string, topGenres int) (inspectReport, error) {
+	report := inspectReport{Path: path}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return report, err
+	}
+	report.FileSize = stat.Size()
+
+	db, err := sqlite.OpenMGDB(path)
+	if err != nil {
+		return report, err
+	}
+	defer db.Close()
+
+	report.Info, err = sqlite.GetMGDBInfo(db)
+	if err != nil {
+		return report, err
+	}
+
+	for _, table := range sqlite.MGDBTables {
+		if ok, err := sqlite.HasTable(db, table); err != nil {
+			return report, err
+		} else if !ok {
+			continue
+		}
+		rows, err := sqlite.CountRows(db, table)
+		if err != nil {
+			return report, err
+		}
+		report.Tables = append(report.Tables, tableCount{Table: table, Rows: rows})
+	}
+
+	counts, err := sqlite.GetGameCoverage(db)
+	if err != nil {
+		return report, err
+	}
+	report.Coverage = gameCoverage{
+		Games:        counts.Games,
+		Indexed:      percent(counts.Indexed, counts.Games),
+		Screenshots:  percent(counts.Screenshots, counts.Games),
+		TitleScreens: percent(counts.TitleScreens, counts.Games),
+		Descriptions: percent(counts.Descriptions, counts.Games),
+		ReleaseDates: percent(counts.ReleaseDates, counts.Games),
+		Developers:   percent(counts.Developers, counts.Games),
+		Publishers:   percent(counts.Publishers, counts.Games),
+		Genres:       percent(counts.Genres, counts.Games),
+	}
+
+	if rdbPath == "" {
+		rdbPath = filepath.Join(filepath.Dir(path), "rdb.ndjson")
+	}
+	report.Crc, err = inspectCrcs(db, rdbPath)
+	if err != nil {
+		return report, err
+	}
+
+	genres, err := sqlite.GetTopGenres(db, topGenres)
+	if err != nil {
+		return report, err
+	}
+	report.TopGenres = []genreCount{}
+	for _, genre := range genres {
+		report.TopGenres = append(report.TopGenres, genreCount{
+			Name: genre.Name, Games: genre.Count, Percent: percent(genre.Count, counts.Games).Percent,
+		})
+	}
+
+	sizes, err := sqlite.GetImageBlobSizes(db)
+	if err != nil {
+		return report, err
+	}
+	report.Images = blobStats(sizes)
+	return report, nil
+}
+
+// Compares RomCrc to the RDB ROMs, nil when no rdb.ndjson is found
+func inspectCrcs(db *sql.DB, rdbPath string) (*crcCoverage, error) {
+	data, err := os.ReadFile(rdbPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	roms, err := rdb.ParseNDJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %v: %w", rdbPath, err)
+	}
+	romCrcs, err := sqlite.GetRomCrcSet(db)
+	if err != nil {
+		return nil, err
+	}
+
+	crcs := &crcCoverage{RdbPath: rdbPath, RdbRoms: len(roms)}
+	rdbCrcs := make(map[string]bool)
+	mapped := 0
+	for _, rom := range roms {
+		crc := strings.ToLower(rom.CRC)
+		if crc == "" {
+			crcs.NoCrc++
+			continue
+		}
+		rdbCrcs[crc] = true
+		if romCrcs[crc] {
+			mapped++
+			continue
+		}
+		crcs.Skipped++
+		if len(crcs.Examples) < 10 {
+			crcs.Examples = append(crcs.Examples, rom.RomName)
+		}
+	}
+	crcs.Mapped = percent(mapped, len(roms)-crcs.NoCrc)
+	for crc := range romCrcs {
+		if !rdbCrcs[crc] {
+			crcs.Unknown++
+		}
+	}
+	return crcs, nil
+}
+
+func blobStats(sizes []int64) imageStats {
+	stats := imageStats{Blobs: len(sizes), Buckets: append([]sizeBucket{}, sizeBuckets...)}
+	if len(sizes) == 0 {
+		return stats
+	}
+	// sizes come sorted from the query
+	stats.Min = sizes[0]
+	stats.Max = sizes[len(sizes)-1]
+	stats.Median = sizes[len(sizes)/2]
+	stats.P90 = sizes[len(sizes)*9/10]
+	for _, size := range sizes {
+		stats.Bytes += size
+		for i := range stats.Buckets {
+			if stats.Buckets[i].Max == 0 || size < stats.Buckets[i].Max {
+				stats.Buckets[i].Count++
+				stats.Buckets[i].Bytes += size
+				break
+			}
+		}
+	}
+	return stats
+}
+
+// Human readable byte size
+func byteSize(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%v B", size)
+	}
+	return fmt.Sprintf("%.1f %v", value, units[unit])
+}
+
+func printReport(report inspectReport) {
+	info := report.Info
+	fmt.Printf("%v (%v)\n", report.Path, byteSize(report.FileSize))
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "  CollectionName\t%v\n", info.CollectionName)
+	fmt.Fprintf(writer, "  GamesFolder\t%v\n", info.GamesFolder)
+	fmt.Fprintf(writer, "  SupportedSystemIds\t%v\n", info.SupportedSystemIds)
+	fmt.Fprintf(writer, "  BuildDate\t%v\n", info.BuildDate)
+	fmt.Fprintf(writer, "  MGDBVersion\t%v\n", info.MGDBVersion)
+	fmt.Fprintf(writer, "  Description\t%v\n", info.Description)
+	if info.RdbRevision != "" || info.RdbSHA256 != "" {
+		fmt.Fprintf(writer, "  RdbRevision\t%v\n", info.RdbRevision)
+		fmt.Fprintf(writer, "  RdbSHA256\t%v\n", info.RdbSHA256)
+	}
+	writer.Flush()
+
+	fmt.Println("\nTables")
+	for _, table := range report.Tables {
+		fmt.Fprintf(writer, "  %v\t%v\n", table.Table, table.Rows)
+	}
+	writer.Flush()
+
+	cov := report.Coverage
+	fmt.Printf("\nCoverage of %v games\n", cov.Games)
+	for _, row := range []struct {
+		label string
+		cov   coverage
+	}{
+		{"Indexed", cov.Indexed},
+		{"Screenshots", cov.Screenshots},
+		{"TitleScreens", cov.TitleScreens},
+		{"Descriptions", cov.Descriptions},
+		{"ReleaseDates", cov.ReleaseDates},
+		{"Developers", cov.Developers},
+		{"Publishers", cov.Publishers},
+		{"Genres", cov.Genres},
+	} {
+		fmt.Fprintf(writer, "  %v\t%v\t%.2f%%\n", row.label, row.cov.Count, row.cov.Percent)
+	}
+	writer.Flush()
+
+	if crcs := report.Crc; crcs != nil {
+		fmt.Printf("\nCRC coverage of %v RDB ROMs in %v\n", crcs.RdbRoms, crcs.RdbPath)
+		fmt.Fprintf(writer, "  Mapped\t%v\t%.2f%%\n", crcs.Mapped.Count, crcs.Mapped.Percent)
+		fmt.Fprintf(writer, "  Skipped\t%v\n", crcs.Skipped)
+		if crcs.NoCrc > 0 {
+			fmt.Fprintf(writer, "  No CRC\t%v\n", crcs.NoCrc)
+		}
+		if crcs.Unknown > 0 {
+			fmt.Fprintf(writer, "  Not in RDB\t%v\n", crcs.Unknown)
+		}
+		writer.Flush()
+		for _, name := range crcs.Examples {
+			fmt.Println("    skipped", name)
+		}
+	} else {
+		fmt.Println("\nNo rdb.ndjson found, skipping CRC coverage")
+	}
+
+	fmt.Println("\nTop genres")
+	for _, genre := range report.TopGenres {
+		fmt.Fprintf(writer, "  %v\t%v\t%.2f%%\n", genre.Name, genre.Games, genre.Percent)
+	}
+	writer.Flush()
+
+	images := report.Images
+	fmt.Printf("\nImages: %v blobs, %v\n", images.Blobs, byteSize(images.Bytes))
+	if images.Blobs > 0 {
+		fmt.Printf("  min %v, median %v, p90 %v, max %v\n",
+			byteSize(images.Min), byteSize(images.Median), byteSize(images.P90), byteSize(images.Max))
+		for _, bucket := range images.Buckets {
+			fmt.Fprintf(writer, "  %v\t%v\t%v\n", bucket.Label, bucket.Count, byteSize(bucket.Bytes))
+		}
+		writer.Flush()
+	}
+}
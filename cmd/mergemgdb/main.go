@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/merge"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+func main() {
+	fmt.Println(os.Args)
+	name := flag.String("name", "All Systems", "CollectionName of the merged MGDB")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		fmt.Println("Usage: mergemgdb [--name {CollectionName}] {out.mgdb} {path.mgdb || folder}...")
+		return
+	}
+	outPath := flag.Arg(0)
+
+	paths, err := sqlite.FindMGDBs(flag.Args()[1:])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// A rerun into the same folder would otherwise merge the last output
+	inputs := []string{}
+	for _, path := range paths {
+		if absPath(path) == absPath(outPath) {
+			continue
+		}
+		inputs = append(inputs, path)
+	}
+
+	summary, err := merge.MGDBs(inputs, outPath, *name)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	printSummary(summary, outPath)
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+func printSummary(summary *merge.Summary, outPath string) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tCOLLECTION\tGAMES\tGAMEIDS\tIMAGES\tNEW IMAGES")
+	for _, collection := range summary.Collections {
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v-%v\t%v\t%v\n",
+			collection.CollectionID, collection.Info.CollectionName, collection.Games,
+			collection.GameOffset+1, collection.GameOffset+collection.Games, collection.Blobs, collection.NewBlobs)
+	}
+	writer.Flush()
+	fmt.Printf("Merged %v games from %v collections into %v\n", summary.Games, len(summary.Collections), outPath)
+	fmt.Printf("%v genres, %v developers, %v publishers, %v images\n",
+		summary.Genres, summary.Developers, summary.Publishers, summary.Blobs)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/config"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/gamelist"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/rdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/romname"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/scraper"
+)
+
+func main() {
+	credentials := scraper.Credentials{}
+	flag.StringVar(&credentials.DevID, "devid", os.Getenv("SCREENSCRAPER_DEVID"), "ScreenScraper developer id")
+	flag.StringVar(&credentials.DevPassword, "devpassword", os.Getenv("SCREENSCRAPER_DEVPASSWORD"), "ScreenScraper developer password")
+	flag.StringVar(&credentials.SoftName, "softname", "MiSTer_Games_Data_Utils", "software name reported to ScreenScraper")
+	flag.StringVar(&credentials.User, "user", os.Getenv("SCREENSCRAPER_USER"), "ScreenScraper user")
+	flag.StringVar(&credentials.Password, "password", os.Getenv("SCREENSCRAPER_PASSWORD"), "ScreenScraper user password")
+	threads := flag.Int("threads", 1, "concurrent lookups, capped by the user's ScreenScraper thread quota")
+	baseURL := flag.String("base-url", scraper.ScreenScraperBaseURL, "ScreenScraper API base URL")
+	replay := flag.String("replay", "", "serve recorded responses from {dir}/{core} instead of ScreenScraper")
+	record := flag.String("record", "", "record raw responses to {dir}/{core}, for use with --replay")
+	cacheDir := flag.String("cache", "", "response and media cache directory (default: cores/{core}/scrapecache)")
+	ttl := flag.Duration("ttl", 30*24*time.Hour, "refetch cached entries older than this, 0 never refetches")
+	offline := flag.Bool("offline", false, "only serve entries from the cache")
+	regions := flag.String("regions", strings.Join(romname.DefaultPreference.Regions, ","), "region priority for names, dates and media")
+	languages := flag.String("languages", strings.Join(romname.DefaultPreference.Languages, ","), "language priority for descriptions and genres")
+	flag.Parse()
+	pref := romname.ParsePreference(*regions, *languages)
+
+	fmt.Println(os.Args)
+	cliArgs := flag.Args()
+	if len(cliArgs) < 1 {
+		fmt.Println("No DataConfig key argument provided")
+		return
+	}
+	configKey := cliArgs[0]
+
+	scrape := func(dataConfig config.DataConfig) error {
+		ss := scraper.NewScreenScraper(credentials, dataConfig.ScreenScraperID)
+		ss.BaseURL = *baseURL
+		ss.SetPreference(pref)
+		if *replay != "" {
+			replayDir := filepath.Join(*replay, dataConfig.ScrapeFolder)
+			server := httptest.NewServer(scraper.ReplayHandler(replayDir))
+			defer server.Close()
+			ss.BaseURL = server.URL
+			fmt.Println("Replaying ScreenScraper responses from", replayDir)
+		}
+		if *record != "" {
+			ss.RecordDir = filepath.Join(*record, dataConfig.ScrapeFolder)
+		}
+
+		corePath := filepath.Join(config.CommandRootPath, "cores", dataConfig.ScrapeFolder)
+		cache := scraper.NewCache(filepath.Join(corePath, "scrapecache"), *ttl)
+		if *cacheDir != "" {
+			cache.Dir = *cacheDir
+		}
+		cache.Offline = *offline
+		// Cached media is cheap to ask for, so refresh it in place
+		options := scraper.ScrapeOptions{Threads: *threads, RefreshMedia: true}
+		err := scrapeGamelist(dataConfig, &scraper.CachedBackend{Backend: ss, Cache: cache}, options)
+		fmt.Println("Cache:", cache.Stats)
+		return err
+	}
+
+	// keyword to process all in sequence
+	if configKey == "all" {
+		for _, dataConfig := range config.DataConfigs {
+			if err := scrape(dataConfig); err != nil {
+				fmt.Println(err)
+				if errors.Is(err, scraper.ErrQuota) {
+					os.Exit(1)
+				}
+			}
+		}
+		return
+	}
+
+	// Else try single
+	dataConfig, ok := config.DataConfigs[configKey]
+	if !ok {
+		fmt.Println("Invalid DataConfig key")
+		return
+	}
+	if err := scrape(dataConfig); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// Scrapes the touched ROMs of a core into gamelist.xml and media for buildmgdb
+func scrapeGamelist(dataConfig config.DataConfig, backend scraper.Backend, options scraper.ScrapeOptions) error {
+	corePath := filepath.Join(config.CommandRootPath, "cores", dataConfig.ScrapeFolder)
+
+	rdbRoms, err := rdb.LoadNDJSON(corePath)
+	if err != nil {
+		fmt.Println("error loading ndjson, scraping by filename only")
+	}
+	roms, err := scraper.TouchedRoms(corePath, rdbRoms)
+	if err != nil {
+		return fmt.Errorf("unable to list touched ROMs for %v: %w", dataConfig.ScrapeFolder, err)
+	}
+	fmt.Printf("Scraping %v ROMs for %v\n", len(roms), dataConfig.ScrapeFolder)
+
+	games, err := scraper.Scrape(backend, corePath, roms, options)
+	if err != nil {
+		// A partial scrape must not replace a complete or curated gamelist
+		fmt.Printf("Scraped %v of %v games, keeping the existing gamelist.xml, rerun to resume from the cache\n", len(games), len(roms))
+		return err
+	}
+	provider := gamelist.Provider{
+		System:   dataConfig.ScrapeFolder,
+		Software: "MiSTer_Games_Data_Utils",
+		Database: backend.Name(),
+		Web:      "https://www.screenscraper.fr",
+	}
+	if err := scraper.WriteGamelist(corePath, provider, games); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %v of %v games to %v\n", len(games), len(roms), filepath.Join(corePath, "gamelist.xml"))
+	return nil
+}
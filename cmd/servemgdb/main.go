@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/server"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	flag.Parse()
+
+	fmt.Println(os.Args)
+	cliArgs := flag.Args()
+	if len(cliArgs) < 1 {
+		fmt.Println("Usage: servemgdb [--addr :8080] {path.mgdb || folder}...")
+		return
+	}
+
+	paths, err := sqlite.FindMGDBs(cliArgs)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	srv, err := server.New(paths)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer srv.Close()
+
+	for _, path := range paths {
+		fmt.Println("Serving", path)
+	}
+	fmt.Printf("Listening on %v\n", *addr)
+	if err := http.ListenAndServe(*addr, srv); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
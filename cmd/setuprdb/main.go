@@ -2,51 +2,123 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/config"
 	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/rdb"
 )
 
+// Repeatable string flag
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
 func main() {
-	cliArgs := os.Args
-	fmt.Println(cliArgs)
-	if len(cliArgs) < 2 {
+	var mirrors stringList
+	flag.Var(&mirrors, "mirror", "RDB base URL or local rdb directory tried before libretro github, repeatable")
+	manifestPath := flag.String("manifest", filepath.Join(config.CommandRootPath, "rdbmanifest.json"), "expected RDB sizes and sha256 hashes")
+	writeManifest := flag.Bool("write-manifest", false, "record fetched RDB sizes and hashes to the manifest")
+	retries := flag.Int("retries", 3, "retries per mirror on network errors and HTTP 5xx")
+	ref := flag.String("ref", "", "libretro-database commit, tag or branch to pin (default: manifest revision, else master)")
+	flag.Parse()
+
+	fmt.Println(os.Args)
+	cliArgs := flag.Args()
+	if len(cliArgs) < 1 {
 		fmt.Println("No DataConfig key argument provided")
 		return
 	}
-	configKey := cliArgs[1]
+	configKey := cliArgs[0]
 
+	manifest, err := rdb.LoadManifest(*manifestPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// Pin the resolved commit, hashes of another revision no longer apply
+	if *ref != "" {
+		revision, err := rdb.ResolveRevision(&http.Client{Timeout: time.Minute}, *ref)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if revision != manifest.Revision {
+			fmt.Printf("Pinning libretro-database %v at %v\n", *ref, revision)
+			manifest.Files = make(map[string]rdb.ManifestEntry)
+			if !*writeManifest {
+				fmt.Println("Run with --write-manifest to record the new pin")
+			}
+		}
+		manifest.Ref = *ref
+		manifest.Revision = revision
+	}
+	if manifest.Revision != "" {
+		fmt.Println("Fetching libretro-database revision", manifest.Revision)
+	}
+
+	fetcher := rdb.NewFetcher(mirrors, manifest.Revision)
+	fetcher.Retries = *retries
+	fetcher.Manifest = manifest
+
+	failed := 0
 	// keyword to process all in sequence
 	if configKey == "all" {
 		for _, dataConfig := range config.DataConfigs {
-			processConfig(dataConfig)
+			if !processConfig(dataConfig, fetcher) {
+				failed++
+			}
+		}
+	} else {
+		// Else try single
+		dataConfig, ok := config.DataConfigs[configKey]
+		if !ok {
+			fmt.Println("Invalid DataConfig key")
+			return
+		}
+		if !processConfig(dataConfig, fetcher) {
+			failed++
 		}
-		return
 	}
 
-	// Else try single
-	dataConfig, ok := config.DataConfigs[configKey]
-	if !ok {
-		fmt.Println("Invalid DataConfig key")
-		return
+	if *writeManifest {
+		if err := manifest.Write(*manifestPath); err != nil {
+			fmt.Println("Unable to write manifest", err)
+			os.Exit(1)
+		}
+		fmt.Println("Wrote manifest", *manifestPath)
+	}
+	if failed > 0 {
+		fmt.Printf("%v RDB fetches failed\n", failed)
+		os.Exit(1)
 	}
-	processConfig(dataConfig)
-
 }
 
-func processConfig(dataConfig config.DataConfig) {
-	rdbPath := fetchRDB(dataConfig)
-	if rdbPath != "" {
-		makeNDJSON(dataConfig, rdbPath)
+func processConfig(dataConfig config.DataConfig, fetcher *rdb.Fetcher) bool {
+	if dataConfig.RdbName == "" {
+		fmt.Printf("no RDB name for %v Skipping\n", dataConfig.ScrapeFolder)
+		return true
+	}
+	rdbPath := fetchRDB(dataConfig, fetcher)
+	if rdbPath == "" {
+		return false
 	}
+	makeNDJSON(dataConfig, rdbPath)
+	return true
 }
 
 func makeNDJSON(dataConfig config.DataConfig, rdbPath string) {
@@ -75,9 +147,9 @@ func makeNDJSON(dataConfig config.DataConfig, rdbPath string) {
 	}
 }
 
-func fetchRDB(dataConfig config.DataConfig) string {
+func fetchRDB(dataConfig config.DataConfig, fetcher *rdb.Fetcher) string {
 	coreLabel := dataConfig.ScrapeFolder
-	dirPath := "/mnt/c/Users/bossr/Code/MiSTer_Games_Data_Utils"
+	dirPath := config.CommandRootPath
 
 	// Make cores dir if not exist
 	coresPath := filepath.Join(dirPath, "cores")
@@ -86,11 +158,6 @@ func fetchRDB(dataConfig config.DataConfig) string {
 		panic(fmt.Sprintf("Unable to create path %v\n", coresPath))
 	}
 	fmt.Println("cores folder ready")
-
-	if dataConfig.RdbName == "" {
-		fmt.Printf("no RDB name for %v Skipping\n", coreLabel)
-		return ""
-	}
 	fmt.Printf("Starting RDB Fetch %s\n", coreLabel)
 
 	// make core dir if not exists
@@ -109,42 +176,26 @@ func fetchRDB(dataConfig config.DataConfig) string {
 	}
 	fmt.Println("cores/roms folder ready")
 
-	// if rdb doesn't exist, download
+	// Fetch when missing or changed upstream, save to cores/{core}/libretro.rdb
 	rdbPath := filepath.Join(corePath, "libretro.rdb")
-	if _, err := os.Stat(rdbPath); errors.Is(err, os.ErrNotExist) {
-		fmt.Printf("Error openingT %s\n", rdbPath)
-		// else fetch remote rdb file, save to filename in cores/{core}/{name}
-		fmtFile := strings.Replace(url.QueryEscape(dataConfig.RdbName), "+", "%20", -1)
-		url := fmt.Sprintf("%s%s", rdb.RootRdbUrl, fmtFile)
-		fmt.Printf("Trying GET %s\n", url)
-		resp, err := http.Get(url)
-		if err != nil {
-			fmt.Printf("Unable to GET url %s, skipping core\n", url)
-			return ""
-		}
-		fmt.Printf("GET StatusCode %v\n", resp.StatusCode)
-
-		fmt.Printf("Trying ReadAll from GET %s\n", dataConfig.RdbName)
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			fmt.Printf("Unable to Read RDB respone from GET url %s, skipping core\n", url)
-			return ""
-		}
-
-		fmt.Printf("Saving RDB Bytes from GET %s\n", dataConfig.RdbName)
-		fo, err := os.Create(rdbPath)
-		if err != nil {
-			fmt.Printf("Unable to write file %s\n", rdbPath)
-			return ""
-		}
-		fo.Write(body)
-		if err := fo.Close(); err != nil {
-			panic(err)
-		}
-		fmt.Println("Saved RDB", dataConfig.RdbName)
+	state, err := fetcher.Fetch(dataConfig.RdbName, rdbPath)
+	if errors.Is(err, rdb.ErrNotModified) {
+		fmt.Println("Existing RDB unchanged upstream, Skipping", dataConfig.RdbName)
+	} else if errors.Is(err, rdb.ErrKeptExisting) {
+		// Not recorded in the manifest, it was not fetched this run
+		fmt.Printf("Unable to fetch %v, using existing RDB: %v\n", dataConfig.RdbName, err)
+		return rdbPath
+	} else if err != nil {
+		fmt.Printf("Unable to fetch %v, skipping core: %v\n", dataConfig.RdbName, err)
+		return ""
 	} else {
-		fmt.Println("Existing RDB, Skipping", dataConfig.RdbName)
+		fmt.Println("Saved RDB", dataConfig.RdbName, state.SHA256)
+	}
+	// Only lock files proven to come from the pinned revision
+	if state.Revision != fetcher.Revision {
+		fmt.Printf("%v not fetched from libretro-database %v, not recorded in the manifest\n", dataConfig.RdbName, fetcher.Revision)
+		return rdbPath
 	}
+	fetcher.Manifest.Files[dataConfig.RdbName] = rdb.ManifestEntry{Size: state.Size, SHA256: state.SHA256}
 	return rdbPath
 }
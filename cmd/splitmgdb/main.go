@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+func main() {
+	cliArgs := os.Args
+	fmt.Println(cliArgs)
+	if len(cliArgs) != 3 {
+		fmt.Println("Usage: splitmgdb {path.mgdb} {outFolder}")
+		return
+	}
+
+	summary, err := splitMGDB(cliArgs[1], cliArgs[2])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for _, path := range []string{summary.MetaPath, summary.MediaPath} {
+		if stat, err := os.Stat(path); err == nil {
+			fmt.Printf("Wrote %v, %v bytes\n", path, stat.Size())
+		}
+	}
+	fmt.Printf("Moved %v images (%v bytes) to the media pack\n", summary.Blobs, summary.BlobBytes)
+}
+
+func splitMGDB(path string, outDir string) (sqlite.SplitSummary, error) {
+	metaPath := filepath.Join(outDir, filepath.Base(path))
+	absPath, _ := filepath.Abs(path)
+	absMetaPath, _ := filepath.Abs(metaPath)
+	if absPath == absMetaPath {
+		return sqlite.SplitSummary{}, fmt.Errorf("outFolder must differ from the folder of %v", path)
+	}
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return sqlite.SplitSummary{}, err
+	}
+	return sqlite.SplitMGDB(path, metaPath)
+}
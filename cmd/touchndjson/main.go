@@ -1,41 +1,59 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/collision"
 	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/config"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/overrides"
 	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/rdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/romname"
 )
 
 func main() {
-	cliArgs := os.Args
-	fmt.Println(cliArgs)
-	if len(cliArgs) < 2 {
+	failOnCollision := flag.Bool("fail-on-collision", false, "exit non-zero when unresolved slug collisions are found")
+	regions := flag.String("regions", strings.Join(romname.DefaultPreference.Regions, ","), "region priority for the ROM touched per slug")
+	languages := flag.String("languages", strings.Join(romname.DefaultPreference.Languages, ","), "language priority for the ROM touched per slug")
+	flag.Parse()
+	pref := romname.ParsePreference(*regions, *languages)
+
+	fmt.Println(os.Args)
+	cliArgs := flag.Args()
+	if len(cliArgs) < 1 {
 		fmt.Println("No DataConfig key argument provided")
 		return
 	}
-	configKey := cliArgs[1]
+	configKey := cliArgs[0]
+
+	collisions := 0
 
 	// keyword to process all in sequence
 	if configKey == "all" {
 		for _, dataConfig := range config.DataConfigs {
-			parseNDJSONAndTouch(dataConfig)
+			collisions += parseNDJSONAndTouch(dataConfig, pref)
 		}
-		return
+	} else {
+		// Else try single
+		dataConfig, ok := config.DataConfigs[configKey]
+		if !ok {
+			fmt.Println("Invalid DataConfig key")
+			return
+		}
+		collisions += parseNDJSONAndTouch(dataConfig, pref)
 	}
 
-	// Else try single
-	dataConfig, ok := config.DataConfigs[configKey]
-	if !ok {
-		fmt.Println("Invalid DataConfig key")
-		return
+	if *failOnCollision && collisions > 0 {
+		fmt.Printf("Failing on %v unresolved slug collisions\n", collisions)
+		os.Exit(1)
 	}
-	parseNDJSONAndTouch(dataConfig)
 }
 
-func parseNDJSONAndTouch(dataConfig config.DataConfig) {
+// returns count of unresolved slug collisions
+func parseNDJSONAndTouch(dataConfig config.DataConfig, pref romname.Preference) int {
 	dirPath := config.CommandRootPath
 	coresPath := filepath.Join(dirPath, "cores")
 	corePath := filepath.Join(coresPath, dataConfig.ScrapeFolder)
@@ -43,10 +61,12 @@ func parseNDJSONAndTouch(dataConfig config.DataConfig) {
 	roms, err := loadNDJSON(corePath)
 	if err != nil {
 		fmt.Println(err)
-		return
+		return 0
 	}
 
-	dupeMap := rdb.MapDupeROMSlugs(roms)
+	unresolved := reportCollisions(dataConfig, corePath, roms)
+
+	dupeMap := rdb.MapDupeROMSlugs(roms, pref, dataConfig.Naming)
 
 	for _, romName := range dupeMap {
 		// Touch file, start empty
@@ -63,6 +83,25 @@ func parseNDJSONAndTouch(dataConfig config.DataConfig) {
 		}
 		fmt.Printf("Closing Game file %s\n", romPath)
 	}
+	return unresolved
+}
+
+func reportCollisions(dataConfig config.DataConfig, corePath string, roms []rdb.RdbJsonROM) int {
+	ovr, err := overrides.Load(corePath)
+	if err != nil {
+		fmt.Println("Unable to load overrides.json", err)
+	}
+
+	detector := collision.NewDetector(dataConfig.Systems, dataConfig.Naming)
+	for _, rom := range roms {
+		detector.AddRdbRom(rom)
+	}
+	collisions := detector.Collisions(ovr.Slug)
+	collision.Print(collisions)
+	if err := collision.WriteJSON(filepath.Join(corePath, collision.RdbReportFile), collisions); err != nil {
+		fmt.Println("Unable to write", collision.RdbReportFile, err)
+	}
+	return collision.Unresolved(collisions)
 }
 
 func loadNDJSON(corePath string) ([]rdb.RdbJsonROM, error) {
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/validate"
+)
+
+func main() {
+	jsonOut := flag.Bool("json", false, "print the reports as JSON")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: validatemgdb [--json] {path.mgdb || folder}...")
+		return
+	}
+
+	paths, err := sqlite.FindMGDBs(flag.Args())
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	passed := true
+	reports := []*validate.Report{}
+	for _, path := range paths {
+		report, err := validate.MGDB(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to validate %v: %v\n", path, err)
+			os.Exit(1)
+		}
+		passed = passed && report.Passed
+		reports = append(reports, report)
+	}
+
+	if *jsonOut {
+		data, _ := json.MarshalIndent(reports, "", "\t")
+		fmt.Println(string(data))
+	} else {
+		for _, report := range reports {
+			printReport(report)
+		}
+	}
+	if !passed {
+		os.Exit(1)
+	}
+}
+
+func printReport(report *validate.Report) {
+	fmt.Println(report.Path)
+	for _, result := range report.Results {
+		status := "PASS"
+		if result.Failures > 0 {
+			status = "FAIL"
+		}
+		fmt.Printf("  %v  %v", status, result.Check)
+		if result.Failures > 0 {
+			fmt.Printf(" (%v)", result.Failures)
+		}
+		if result.Note != "" {
+			fmt.Printf(", %v", result.Note)
+		}
+		fmt.Println()
+		for _, example := range result.Examples {
+			fmt.Println("        ", example)
+		}
+	}
+	if report.Passed {
+		fmt.Println("Valid")
+	} else {
+		fmt.Println("Invalid")
+	}
+}
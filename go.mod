@@ -2,7 +2,6 @@ module github.com/BossRighteous/MiSTer_Games_Data_Utils
 
 go 1.18
 
-require (
-	github.com/mattn/go-sqlite3 v1.14.22 // indirect
-	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8 // indirect
-)
+require github.com/mattn/go-sqlite3 v1.14.22
+
+require golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8 // indirect
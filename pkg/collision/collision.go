@@ -0,0 +1,218 @@
+package collision
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/gamelist"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mister"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/rdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/romname"
+)
+
+const (
+	SourceRdb      = "rdb"
+	SourceGamelist = "gamelist"
+)
+
+// Report file names in cores/{core}, touchndjson only sees the RDB while
+// buildmgdb also checks the gamelist, so they keep separate reports
+const (
+	RdbReportFile   = "collisions.rdb.json"
+	BuildReportFile = "collisions.json"
+)
+
+// Entry is one ROM or gamelist game competing for a slug
+type Entry struct {
+	Source     string `json:"source"`
+	RomName    string `json:"rom_name"`
+	CRC        string `json:"crc,omitempty"`
+	GamelistID string `json:"gamelist_id,omitempty"`
+	Publisher  string `json:"publisher,omitempty"`
+	SystemId   string `json:"system_id,omitempty"`
+}
+
+// Collision is a slug shared by entries that look like distinct games
+type Collision struct {
+	Slug        string   `json:"slug"`
+	Reasons     []string `json:"reasons"`
+	RomNames    []string `json:"rom_names"`
+	CRCs        []string `json:"crcs"`
+	GamelistIDs []string `json:"gamelist_ids"`
+	Entries     []Entry  `json:"entries"`
+	Resolved    bool     `json:"resolved"`
+}
+
+// Detector collects entries by slug. Slugs that merge entries from different
+// gamelist games, publishers or CoreGroup systems are reported as collisions.
+// Region variants of one game share a slug by design and are not reported.
+// An empty RDB publisher is unknown, not a match, so without a gamelist game
+// tying them together such ROMs are reported too.
+type Detector struct {
+	systems    []mister.System
+	convention romname.Convention
+	slugs      map[string][]Entry
+}
+
+func NewDetector(systems []mister.System, convention romname.Convention) *Detector {
+	return &Detector{
+		systems:    systems,
+		convention: convention,
+		slugs:      make(map[string][]Entry),
+	}
+}
+
+func (det *Detector) add(slug string, entry Entry) {
+	if slug == "" {
+		return
+	}
+	entry.SystemId = det.systemIdForFile(entry.RomName)
+	det.slugs[slug] = append(det.slugs[slug], entry)
+}
+
+func (det *Detector) AddRdbRom(rom rdb.RdbJsonROM) {
+	det.add(romname.Slugify(rom.RomName, det.convention), Entry{
+		Source:    SourceRdb,
+		RomName:   rom.RomName,
+		CRC:       rom.CRC,
+		Publisher: rom.Publisher,
+	})
+}
+
+func (det *Detector) AddGamelistGame(game gamelist.Game) {
+	fileBase := filepath.Base(game.Path)
+	det.add(romname.Slugify(fileBase, det.convention), Entry{
+		Source:     SourceGamelist,
+		RomName:    fileBase,
+		GamelistID: game.ID,
+		Publisher:  game.Publisher,
+	})
+}
+
+// CoreGroup systems are told apart by slot extension, first match wins
+func (det *Detector) systemIdForFile(romName string) string {
+	if len(det.systems) < 2 {
+		return ""
+	}
+	for _, system := range det.systems {
+		if _, err := mister.PathToMglDef(system, romName); err == nil {
+			if system.Id != "" {
+				return system.Id
+			}
+			return system.Name
+		}
+	}
+	return ""
+}
+
+func distinct(values []string) []string {
+	seen := make(map[string]bool)
+	out := []string{}
+	for _, value := range values {
+		key := strings.ToLower(strings.TrimSpace(value))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, value)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Collisions returns all colliding slugs sorted by slug.
+// resolved marks slugs already pinned by a curated override.
+func (det *Detector) Collisions(resolved map[string]string) []Collision {
+	collisions := []Collision{}
+	for slug, entries := range det.slugs {
+		if len(entries) < 2 {
+			continue
+		}
+		var romNames, crcs, gamelistIDs, rdbPublishers, systemIds, noPublisher []string
+		rdbRoms := 0
+		for _, entry := range entries {
+			romNames = append(romNames, entry.RomName)
+			crcs = append(crcs, entry.CRC)
+			gamelistIDs = append(gamelistIDs, entry.GamelistID)
+			systemIds = append(systemIds, entry.SystemId)
+			if entry.Source == SourceRdb {
+				rdbRoms++
+				rdbPublishers = append(rdbPublishers, entry.Publisher)
+				if strings.TrimSpace(entry.Publisher) == "" {
+					noPublisher = append(noPublisher, entry.RomName)
+				}
+			}
+		}
+
+		reasons := []string{}
+		gamelistIDs = distinct(gamelistIDs)
+		if len(gamelistIDs) > 1 {
+			reasons = append(reasons, "multiple gamelist games")
+		}
+		if publishers := distinct(rdbPublishers); len(publishers) > 1 {
+			reasons = append(reasons, fmt.Sprintf("multiple publishers: %v", strings.Join(publishers, ", ")))
+		}
+		if len(gamelistIDs) == 0 && rdbRoms > 1 && len(noPublisher) > 0 {
+			reasons = append(reasons, fmt.Sprintf("unknown publisher: %v", strings.Join(distinct(noPublisher), ", ")))
+		}
+		if systemIds = distinct(systemIds); len(systemIds) > 1 {
+			reasons = append(reasons, fmt.Sprintf("multiple systems: %v", strings.Join(systemIds, ", ")))
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+
+		_, isResolved := resolved[slug]
+		collisions = append(collisions, Collision{
+			Slug:        slug,
+			Reasons:     reasons,
+			RomNames:    distinct(romNames),
+			CRCs:        distinct(crcs),
+			GamelistIDs: gamelistIDs,
+			Entries:     entries,
+			Resolved:    isResolved,
+		})
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].Slug < collisions[j].Slug
+	})
+	return collisions
+}
+
+// Unresolved counts collisions not pinned by an override
+func Unresolved(collisions []Collision) int {
+	count := 0
+	for _, collision := range collisions {
+		if !collision.Resolved {
+			count++
+		}
+	}
+	return count
+}
+
+func Print(collisions []Collision) {
+	fmt.Printf("Slug collisions: %v (%v unresolved)\n", len(collisions), Unresolved(collisions))
+	for _, collision := range collisions {
+		state := ""
+		if collision.Resolved {
+			state = " [override]"
+		}
+		fmt.Printf("  %v%v: %v\n", collision.Slug, state, strings.Join(collision.Reasons, "; "))
+		for _, entry := range collision.Entries {
+			fmt.Printf("    %-8v %v crc=%v id=%v\n", entry.Source, entry.RomName, entry.CRC, entry.GamelistID)
+		}
+	}
+}
+
+// WriteJSON writes the machine readable report, RdbReportFile or BuildReportFile in cores/{core}
+func WriteJSON(path string, collisions []Collision) error {
+	data, err := json.MarshalIndent(collisions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -1,59 +1,66 @@
 package config
 
-import "github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mister"
+import (
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mister"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/romname"
+)
 
 type DataConfig struct {
-	ScrapeFolder string
-	RdbName      string
-	Systems      []mister.System
+	ScrapeFolder    string
+	RdbName         string
+	Systems         []mister.System
+	Naming          romname.Convention // ROM set naming, No-Intro if empty
+	ScreenScraperID int                // ScreenScraper systemeid, 0 scrapes without a system hint
 }
 
 var CommandRootPath string = "/mnt/c/Users/bossr/Code/MiSTer_Games_Data_Utils"
 
 var DataConfigs map[string]DataConfig = map[string]DataConfig{
-	"ATARI5200":       {ScrapeFolder: "ATARI5200", RdbName: "Atari - 5200.rdb", Systems: []mister.System{mister.Systems["Atari5200"]}},
-	"ATARI7800":       {ScrapeFolder: "ATARI7800", RdbName: "Atari - 7800.rdb", Systems: mister.CoreGroups["Atari7800"]},
-	"ATARI800":        {ScrapeFolder: "ATARI800", RdbName: "Atari - 8-bit.rdb", Systems: []mister.System{mister.Systems["Atari800"]}},
-	"AVision":         {ScrapeFolder: "AVision", RdbName: "Entex - Adventure Vision.rdb", Systems: []mister.System{mister.Systems["AdventureVision"]}},
-	"Amiga":           {ScrapeFolder: "Amiga", RdbName: "Commodore - Amiga.rdb", Systems: []mister.System{mister.Systems["Amiga"]}},
-	"Amstrad":         {ScrapeFolder: "Amstrad", RdbName: "Amstrad - CPC.rdb", Systems: []mister.System{mister.Systems["Amstrad"]}},
-	"Arcadia":         {ScrapeFolder: "Arcadia", RdbName: "Emerson - Arcadia 2001.rdb", Systems: []mister.System{mister.Systems["Arcadia"]}},
-	"Atari2600":       {ScrapeFolder: "Atari2600", RdbName: "Atari - 2600.rdb", Systems: []mister.System{mister.Systems["Atari2600"]}},
-	"AtariLynx":       {ScrapeFolder: "AtariLynx", RdbName: "Atari - Lynx.rdb", Systems: []mister.System{mister.Systems["AtariLynx"]}},
-	"C64":             {ScrapeFolder: "C64", RdbName: "Commodore - 64.rdb", Systems: []mister.System{mister.Systems["C64"]}},
-	"Casio_PV-1000":   {ScrapeFolder: "Casio_PV-1000", RdbName: "Casio - PV-1000.rdb", Systems: []mister.System{mister.Systems["CasioPV1000"]}},
-	"ChannelF":        {ScrapeFolder: "ChannelF", RdbName: "Fairchild - Channel F.rdb", Systems: []mister.System{mister.Systems["ChannelF"]}},
-	"Coleco":          {ScrapeFolder: "Coleco", RdbName: "Coleco - ColecoVision.rdb", Systems: mister.CoreGroups["Coleco"]},
-	"CreatiVision":    {ScrapeFolder: "CreatiVision", RdbName: "VTech - CreatiVision.rdb", Systems: []mister.System{mister.Systems["CreatiVision"]}},
-	"GAMEBOY":         {ScrapeFolder: "GAMEBOY", RdbName: "Nintendo - Game Boy.rdb", Systems: mister.CoreGroups["Gameboy"]},
-	"GBA":             {ScrapeFolder: "GBA", RdbName: "Nintendo - Game Boy Advance.rdb", Systems: []mister.System{mister.Systems["GBA"]}},
-	"GBC":             {ScrapeFolder: "GBC", RdbName: "Nintendo - Game Boy Color.rdb", Systems: []mister.System{mister.Systems["GameboyColor"]}},
-	"GameGear":        {ScrapeFolder: "GameGear", RdbName: "Sega - Game Gear.rdb", Systems: []mister.System{mister.Systems["GameGear"]}},
-	"Genesis":         {ScrapeFolder: "Genesis", RdbName: "Sega - Mega Drive - Genesis.rdb", Systems: []mister.System{mister.Systems["Genesis"]}},
-	"Intellivision":   {ScrapeFolder: "Intellivision", RdbName: "Mattel - Intellivision.rdb", Systems: []mister.System{mister.Systems["Intellivision"]}},
-	"MSX":             {ScrapeFolder: "MSX", RdbName: "Microsoft - MSX.rdb", Systems: []mister.System{mister.Systems["MSX"]}},
-	"MegaCD":          {ScrapeFolder: "MegaCD", RdbName: "Sega - Mega-CD - Sega CD.rdb", Systems: []mister.System{mister.Systems["MegaCD"]}},
-	"N64":             {ScrapeFolder: "N64", RdbName: "Nintendo - Nintendo 64.rdb", Systems: []mister.System{mister.Systems["Nintendo64"]}},
-	"NEOGEO":          {ScrapeFolder: "NEOGEO", RdbName: "SNK - Neo Geo.rdb", Systems: []mister.System{mister.Systems["NeoGeo"]}},
-	"NES":             {ScrapeFolder: "NES", RdbName: "Nintendo - Nintendo Entertainment System.rdb", Systems: mister.CoreGroups["NES"]},
-	"ODYSSEY2":        {ScrapeFolder: "ODYSSEY2", RdbName: "Magnavox - Odyssey2.rdb", Systems: []mister.System{mister.Systems["Odyssey2"]}},
-	"PET2001":         {ScrapeFolder: "PET2001", RdbName: "Commodore - PET.rdb", Systems: []mister.System{mister.Systems["PET2001"]}},
-	"PSX":             {ScrapeFolder: "PSX", RdbName: "Sony - PlayStation.rdb", Systems: []mister.System{mister.Systems["PSX"]}},
-	"S32X":            {ScrapeFolder: "S32X", RdbName: "Sega - 32X.rdb", Systems: []mister.System{mister.Systems["Sega32X"]}},
-	"Saturn":          {ScrapeFolder: "Saturn", RdbName: "Sega - Saturn.rdb", Systems: []mister.System{mister.Systems["Saturn"]}},
-	"SMS":             {ScrapeFolder: "SMS", RdbName: "Sega - Master System - Mark III.rdb", Systems: mister.CoreGroups["SMS"]},
-	"SNES":            {ScrapeFolder: "SNES", RdbName: "Nintendo - Super Nintendo Entertainment System.rdb", Systems: mister.CoreGroups["SNES"]},
-	"Spectrum":        {ScrapeFolder: "Spectrum", RdbName: "Sinclair - ZX Spectrum.rdb", Systems: []mister.System{mister.Systems["ZXSpectrum"]}},
-	"SVI328":          {ScrapeFolder: "SVI328", RdbName: "Spectravideo - SVI-318 - SVI-328.rdb", Systems: []mister.System{mister.Systems["SVI328"]}},
-	"SuperVision":     {ScrapeFolder: "SuperVision", RdbName: "Watara - Supervision.rdb", Systems: []mister.System{mister.Systems["SuperVision"]}},
-	"TGFX16":          {ScrapeFolder: "TGFX16", RdbName: "NEC - PC Engine - TurboGrafx 16.rdb", Systems: mister.CoreGroups["TGFX16"]},
-	"TGFX16-CD":       {ScrapeFolder: "TGFX16-CD", RdbName: "NEC - PC Engine CD - TurboGrafx-CD.rdb", Systems: []mister.System{mister.Systems["TurboGrafx16CD"]}},
-	"VECTREX":         {ScrapeFolder: "VECTREX", RdbName: "GCE - Vectrex.rdb", Systems: []mister.System{mister.Systems["Vectrex"]}},
-	"VIC20":           {ScrapeFolder: "VIC20", RdbName: "Commodore - VIC-20.rdb", Systems: []mister.System{mister.Systems["VIC20"]}},
-	"WonderSwan":      {ScrapeFolder: "WonderSwan", RdbName: "Bandai - WonderSwan.rdb", Systems: []mister.System{mister.Systems["WonderSwan"]}},
-	"WonderSwanColor": {ScrapeFolder: "WonderSwanColor", RdbName: "Bandai - WonderSwan Color.rdb", Systems: []mister.System{mister.Systems["WonderSwanColor"]}},
-	"X68000":          {ScrapeFolder: "X68000", RdbName: "Sharp - X68000.rdb", Systems: []mister.System{mister.Systems["X68000"]}},
-	"ZX81":            {ScrapeFolder: "ZX81", RdbName: "Sinclair - ZX 81.rdb", Systems: []mister.System{mister.Systems["ZX81"]}},
+	"ATARI5200":       {ScrapeFolder: "ATARI5200", RdbName: "Atari - 5200.rdb", Systems: []mister.System{mister.Systems["Atari5200"]}, ScreenScraperID: 40},
+	"ATARI7800":       {ScrapeFolder: "ATARI7800", RdbName: "Atari - 7800.rdb", Systems: mister.CoreGroups["Atari7800"], ScreenScraperID: 41},
+	"ATARI800":        {ScrapeFolder: "ATARI800", RdbName: "Atari - 8-bit.rdb", Systems: []mister.System{mister.Systems["Atari800"]}, Naming: romname.ConventionTOSEC, ScreenScraperID: 43},
+	"AVision":         {ScrapeFolder: "AVision", RdbName: "Entex - Adventure Vision.rdb", Systems: []mister.System{mister.Systems["AdventureVision"]}, ScreenScraperID: 78},
+	"Amiga":           {ScrapeFolder: "Amiga", RdbName: "Commodore - Amiga.rdb", Systems: []mister.System{mister.Systems["Amiga"]}, Naming: romname.ConventionTOSEC, ScreenScraperID: 64},
+	"Amstrad":         {ScrapeFolder: "Amstrad", RdbName: "Amstrad - CPC.rdb", Systems: []mister.System{mister.Systems["Amstrad"]}, Naming: romname.ConventionTOSEC, ScreenScraperID: 65},
+	"Arcadia":         {ScrapeFolder: "Arcadia", RdbName: "Emerson - Arcadia 2001.rdb", Systems: []mister.System{mister.Systems["Arcadia"]}, ScreenScraperID: 94},
+	"Atari2600":       {ScrapeFolder: "Atari2600", RdbName: "Atari - 2600.rdb", Systems: []mister.System{mister.Systems["Atari2600"]}, ScreenScraperID: 26},
+	"AtariLynx":       {ScrapeFolder: "AtariLynx", RdbName: "Atari - Lynx.rdb", Systems: []mister.System{mister.Systems["AtariLynx"]}, ScreenScraperID: 28},
+	"C64":             {ScrapeFolder: "C64", RdbName: "Commodore - 64.rdb", Systems: []mister.System{mister.Systems["C64"]}, Naming: romname.ConventionTOSEC, ScreenScraperID: 66},
+	"Casio_PV-1000":   {ScrapeFolder: "Casio_PV-1000", RdbName: "Casio - PV-1000.rdb", Systems: []mister.System{mister.Systems["CasioPV1000"]}, ScreenScraperID: 74},
+	"ChannelF":        {ScrapeFolder: "ChannelF", RdbName: "Fairchild - Channel F.rdb", Systems: []mister.System{mister.Systems["ChannelF"]}, ScreenScraperID: 80},
+	"Coleco":          {ScrapeFolder: "Coleco", RdbName: "Coleco - ColecoVision.rdb", Systems: mister.CoreGroups["Coleco"], ScreenScraperID: 48},
+	"CreatiVision":    {ScrapeFolder: "CreatiVision", RdbName: "VTech - CreatiVision.rdb", Systems: []mister.System{mister.Systems["CreatiVision"]}, ScreenScraperID: 241},
+	"GAMEBOY":         {ScrapeFolder: "GAMEBOY", RdbName: "Nintendo - Game Boy.rdb", Systems: mister.CoreGroups["Gameboy"], ScreenScraperID: 9},
+	"GBA":             {ScrapeFolder: "GBA", RdbName: "Nintendo - Game Boy Advance.rdb", Systems: []mister.System{mister.Systems["GBA"]}, ScreenScraperID: 12},
+	"GBC":             {ScrapeFolder: "GBC", RdbName: "Nintendo - Game Boy Color.rdb", Systems: []mister.System{mister.Systems["GameboyColor"]}, ScreenScraperID: 10},
+	"GameGear":        {ScrapeFolder: "GameGear", RdbName: "Sega - Game Gear.rdb", Systems: []mister.System{mister.Systems["GameGear"]}, ScreenScraperID: 21},
+	"Genesis":         {ScrapeFolder: "Genesis", RdbName: "Sega - Mega Drive - Genesis.rdb", Systems: []mister.System{mister.Systems["Genesis"]}, ScreenScraperID: 1},
+	"Intellivision":   {ScrapeFolder: "Intellivision", RdbName: "Mattel - Intellivision.rdb", Systems: []mister.System{mister.Systems["Intellivision"]}, ScreenScraperID: 115},
+	"MSX":             {ScrapeFolder: "MSX", RdbName: "Microsoft - MSX.rdb", Systems: []mister.System{mister.Systems["MSX"]}, Naming: romname.ConventionTOSEC, ScreenScraperID: 113},
+	"MegaCD":          {ScrapeFolder: "MegaCD", RdbName: "Sega - Mega-CD - Sega CD.rdb", Systems: []mister.System{mister.Systems["MegaCD"]}, ScreenScraperID: 20},
+	"N64":             {ScrapeFolder: "N64", RdbName: "Nintendo - Nintendo 64.rdb", Systems: []mister.System{mister.Systems["Nintendo64"]}, ScreenScraperID: 14},
+	"NEOGEO":          {ScrapeFolder: "NEOGEO", RdbName: "SNK - Neo Geo.rdb", Systems: []mister.System{mister.Systems["NeoGeo"]}, ScreenScraperID: 142},
+	"NES":             {ScrapeFolder: "NES", RdbName: "Nintendo - Nintendo Entertainment System.rdb", Systems: mister.CoreGroups["NES"], ScreenScraperID: 3},
+	"ODYSSEY2":        {ScrapeFolder: "ODYSSEY2", RdbName: "Magnavox - Odyssey2.rdb", Systems: []mister.System{mister.Systems["Odyssey2"]}, ScreenScraperID: 104},
+	"PET2001":         {ScrapeFolder: "PET2001", RdbName: "Commodore - PET.rdb", Systems: []mister.System{mister.Systems["PET2001"]}, ScreenScraperID: 240},
+	"PSX":             {ScrapeFolder: "PSX", RdbName: "Sony - PlayStation.rdb", Systems: []mister.System{mister.Systems["PSX"]}, ScreenScraperID: 57},
+	"S32X":            {ScrapeFolder: "S32X", RdbName: "Sega - 32X.rdb", Systems: []mister.System{mister.Systems["Sega32X"]}, ScreenScraperID: 19},
+	"Saturn":          {ScrapeFolder: "Saturn", RdbName: "Sega - Saturn.rdb", Systems: []mister.System{mister.Systems["Saturn"]}, ScreenScraperID: 22},
+	"SMS":             {ScrapeFolder: "SMS", RdbName: "Sega - Master System - Mark III.rdb", Systems: mister.CoreGroups["SMS"], ScreenScraperID: 2},
+	"SNES":            {ScrapeFolder: "SNES", RdbName: "Nintendo - Super Nintendo Entertainment System.rdb", Systems: mister.CoreGroups["SNES"], ScreenScraperID: 4},
+	"Spectrum":        {ScrapeFolder: "Spectrum", RdbName: "Sinclair - ZX Spectrum.rdb", Systems: []mister.System{mister.Systems["ZXSpectrum"]}, Naming: romname.ConventionTOSEC, ScreenScraperID: 76},
+	"SVI328":          {ScrapeFolder: "SVI328", RdbName: "Spectravideo - SVI-318 - SVI-328.rdb", Systems: []mister.System{mister.Systems["SVI328"]}, ScreenScraperID: 218},
+	"SuperVision":     {ScrapeFolder: "SuperVision", RdbName: "Watara - Supervision.rdb", Systems: []mister.System{mister.Systems["SuperVision"]}, ScreenScraperID: 207},
+	"TGFX16":          {ScrapeFolder: "TGFX16", RdbName: "NEC - PC Engine - TurboGrafx 16.rdb", Systems: mister.CoreGroups["TGFX16"], ScreenScraperID: 31},
+	"TGFX16-CD":       {ScrapeFolder: "TGFX16-CD", RdbName: "NEC - PC Engine CD - TurboGrafx-CD.rdb", Systems: []mister.System{mister.Systems["TurboGrafx16CD"]}, ScreenScraperID: 114},
+	"VECTREX":         {ScrapeFolder: "VECTREX", RdbName: "GCE - Vectrex.rdb", Systems: []mister.System{mister.Systems["Vectrex"]}, ScreenScraperID: 102},
+	"VIC20":           {ScrapeFolder: "VIC20", RdbName: "Commodore - VIC-20.rdb", Systems: []mister.System{mister.Systems["VIC20"]}, ScreenScraperID: 73},
+	"WonderSwan":      {ScrapeFolder: "WonderSwan", RdbName: "Bandai - WonderSwan.rdb", Systems: []mister.System{mister.Systems["WonderSwan"]}, ScreenScraperID: 45},
+	"WonderSwanColor": {ScrapeFolder: "WonderSwanColor", RdbName: "Bandai - WonderSwan Color.rdb", Systems: []mister.System{mister.Systems["WonderSwanColor"]}, ScreenScraperID: 46},
+	"X68000":          {ScrapeFolder: "X68000", RdbName: "Sharp - X68000.rdb", Systems: []mister.System{mister.Systems["X68000"]}, Naming: romname.ConventionTOSEC, ScreenScraperID: 79},
+	"ZX81":            {ScrapeFolder: "ZX81", RdbName: "Sinclair - ZX 81.rdb", Systems: []mister.System{mister.Systems["ZX81"]}, ScreenScraperID: 77},
 
 	// PROBLEM SCRAPING
 	//"AO486": {MisterCoreFolder: "AO486", RdbName: "DOS.rdb"},
@@ -134,3 +141,29 @@ var DataConfigs map[string]DataConfig = map[string]DataConfig{
 	//"ZXNext": {MisterCoreFolder:"ZXNext", RdbName: ""},
 	//"eg2000": {MisterCoreFolder:"eg2000", RdbName: ""},
 }
+
+// DataConfigForFolder finds the DataConfig an MGDB was built from,
+// matched on MGDBInfo.GamesFolder
+func DataConfigForFolder(folder string) (DataConfig, bool) {
+	for _, dataConfig := range DataConfigs {
+		if dataConfig.ScrapeFolder == folder {
+			return dataConfig, true
+		}
+	}
+	return DataConfig{}, false
+}
+
+// SystemsForMGDB resolves the CoreGroup of an MGDB, falling back to
+// its comma separated SupportedSystemIds
+func SystemsForMGDB(gamesFolder string, supportedSystemIds string) []mister.System {
+	if dataConfig, ok := DataConfigForFolder(gamesFolder); ok {
+		return dataConfig.Systems
+	}
+	systems := []mister.System{}
+	for _, id := range strings.Split(supportedSystemIds, ",") {
+		if system, ok := mister.Systems[strings.TrimSpace(id)]; ok {
+			systems = append(systems, system)
+		}
+	}
+	return systems
+}
@@ -0,0 +1,704 @@
+package delta
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/rdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+// Version of the patch layout, bumped when older apply commands can't read it
+const Version = "2"
+
+var (
+	ErrSchemaChanged  = errors.New("MGDB schema changed between releases, publish the full MGDB")
+	ErrBaseMismatch   = errors.New("base MGDB does not match the patch")
+	ErrResultMismatch = errors.New("patched MGDB does not match the release")
+	ErrSplitMismatch  = errors.New("only one MGDB is split, split both or neither")
+	ErrBadPatch       = errors.New("patch does not match the MGDB")
+)
+
+// Info is the DeltaInfo row of a patch. The base is checked by file hash,
+// falling back to content hash for MGDBs indexed or patched locally.
+// The result can only be checked by content hash.
+type Info struct {
+	DeltaVersion      string
+	BaseName          string
+	BaseSHA256        string
+	BaseContentHash   string
+	ResultName        string
+	ResultSHA256      string
+	ResultContentHash string
+	CreatedDate       string
+}
+
+// Summary counts the rows a patch changes
+type Summary struct {
+	Info      Info
+	Deletes   map[string]int // per table
+	Upserts   map[string]int // per table
+	Rows      int            // deleted and upserted rows
+	Blobs     int
+	BlobBytes int64
+	// IndexedRom rows kept by Apply and moved to their game's new GameID
+	IndexedRoms int
+	// IndexedRom rows whose game could not be found again, now unmatched
+	LostRoms int
+}
+
+func newSummary(info Info) *Summary {
+	return &Summary{Info: info, Deletes: make(map[string]int), Upserts: make(map[string]int)}
+}
+
+// A patch is itself a SQLite file: DeltaInfo, then per changed table in
+// TableChange order the primary keys of removed rows in Delete_{table} and
+// new or changed rows in Upsert_{table}, then new ImageBlob rows. Patches
+// hold row data only, Apply builds the SQL from the patched MGDB's own
+// schema. Rows are keyed as built, buildmgdb numbers GameIDs by position so
+// one added game moves every later game and its SlugRom and RomTag rows
+// into the patch.
+const patchSchema = `
+create table DeltaInfo (
+	DeltaVersion text not null,
+	BaseName text not null,
+	BaseSHA256 text not null,
+	BaseContentHash text not null,
+	ResultName text not null,
+	ResultSHA256 text not null,
+	ResultContentHash text not null,
+	CreatedDate text not null
+);
+create table TableChange (
+	Seq integer primary key not null,
+	TableName text not null,
+	ClearAll integer not null
+);
+create table ImageBlob (
+	Hash text primary key not null,
+	Bytes blob not null
+);`
+
+func deleteTable(table string) string {
+	return sqlite.QuoteIdent("Delete_" + table)
+}
+
+func upsertTable(table string) string {
+	return sqlite.QuoteIdent("Upsert_" + table)
+}
+
+func quoteNames(names []string) string {
+	quoted := []string{}
+	for _, name := range names {
+		quoted = append(quoted, sqlite.QuoteIdent(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func columnNames(columns []sqlite.Column) []string {
+	names := []string{}
+	for _, column := range columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+func tableSchemas(db *sql.DB, schema string) (map[string]string, error) {
+	schemas := make(map[string]string)
+	rows, err := db.Query(fmt.Sprintf(
+		"select name, coalesce(sql, '') from %v.sqlite_master where name not like 'sqlite_%%'", sqlite.QuoteIdent(schema),
+	))
+	if err != nil {
+		return schemas, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		name, sql := "", ""
+		if err := rows.Scan(&name, &sql); err != nil {
+			return schemas, err
+		}
+		schemas[name] = strings.Join(strings.Fields(sql), " ")
+	}
+	return schemas, rows.Err()
+}
+
+func sameSchema(db *sql.DB) error {
+	baseSchemas, err := tableSchemas(db, "base")
+	if err != nil {
+		return err
+	}
+	resultSchemas, err := tableSchemas(db, "result")
+	if err != nil {
+		return err
+	}
+	if len(baseSchemas) != len(resultSchemas) {
+		return ErrSchemaChanged
+	}
+	for name, sql := range baseSchemas {
+		if resultSchemas[name] != sql {
+			return fmt.Errorf("%w: %v", ErrSchemaChanged, name)
+		}
+	}
+	return nil
+}
+
+// Patches cover one file. A split MGDB and its media pack are patched
+// separately, each hashed without the other.
+func contentHash(path string) (string, error) {
+	db, err := sqlite.OpenMGDBFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	return sqlite.ContentHash(db)
+}
+
+func attach(db *sql.DB, path string, schema string) error {
+	_, err := db.Exec(fmt.Sprintf("attach database ? as %v", sqlite.QuoteIdent(schema)), path)
+	return err
+}
+
+func isSplit(path string) bool {
+	_, err := os.Stat(sqlite.MediaPath(path))
+	return err == nil
+}
+
+// Create writes a patch turning the MGDB at basePath into the one at resultPath.
+// Split MGDBs need a patch for the MGDB and one for its media pack, a split
+// and an unsplit release can't be patched into one another.
+func Create(basePath string, resultPath string, patchPath string) (*Summary, error) {
+	if !sqlite.IsMediaPath(basePath) && isSplit(basePath) != isSplit(resultPath) {
+		return nil, ErrSplitMismatch
+	}
+	info := Info{
+		DeltaVersion: Version,
+		BaseName:     filepath.Base(basePath),
+		ResultName:   filepath.Base(resultPath),
+		CreatedDate:  time.Now().UTC().Format(time.RFC3339),
+	}
+	var err error
+	if info.BaseSHA256, err = rdb.FileSHA256(basePath); err != nil {
+		return nil, err
+	}
+	if info.ResultSHA256, err = rdb.FileSHA256(resultPath); err != nil {
+		return nil, err
+	}
+	if info.BaseContentHash, err = contentHash(basePath); err != nil {
+		return nil, fmt.Errorf("unable to hash %v: %w", basePath, err)
+	}
+	if info.ResultContentHash, err = contentHash(resultPath); err != nil {
+		return nil, fmt.Errorf("unable to hash %v: %w", resultPath, err)
+	}
+	summary := newSummary(info)
+
+	if err := os.Remove(patchPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", patchPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	// attached schemas are per connection
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(patchSchema); err != nil {
+		return nil, err
+	}
+	db.Exec("pragma synchronous = off")
+	db.Exec("pragma journal_mode = off")
+	if err := attach(db, basePath, "base"); err != nil {
+		return nil, err
+	}
+	if err := attach(db, resultPath, "result"); err != nil {
+		return nil, err
+	}
+	if err := sameSchema(db); err != nil {
+		return nil, err
+	}
+
+	tables, err := sqlite.GetTableNames(db, "result")
+	if err != nil {
+		return nil, err
+	}
+	changes := []tableChange{}
+	for _, table := range tables {
+		if sqlite.LocalTables[table] {
+			continue
+		}
+		change, err := diffTable(db, table, summary)
+		if err != nil {
+			return nil, fmt.Errorf("unable to diff %v: %w", table, err)
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(
+		"insert into DeltaInfo values (?, ?, ?, ?, ?, ?, ?, ?)",
+		info.DeltaVersion, info.BaseName, info.BaseSHA256, info.BaseContentHash,
+		info.ResultName, info.ResultSHA256, info.ResultContentHash, info.CreatedDate,
+	); err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		if _, err := tx.Exec("insert into TableChange (TableName, ClearAll) values (?, ?)", change.table, change.clearAll); err != nil {
+			return nil, err
+		}
+	}
+
+	// Blobs are keyed by content hash, only new ones need shipping
+	if _, err := tx.Exec(
+		"insert into main.ImageBlob (Hash, Bytes) select Hash, Bytes from result.ImageBlob " +
+			"where Hash not in (select Hash from base.ImageBlob)",
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.QueryRow("select count(*), coalesce(sum(length(Bytes)), 0) from main.ImageBlob").Scan(
+		&summary.Blobs, &summary.BlobBytes,
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	db.Exec("detach database base")
+	db.Exec("detach database result")
+	db.Exec("vacuum")
+	return summary, nil
+}
+
+type tableChange struct {
+	table    string
+	clearAll bool
+}
+
+// diffTable copies the row changes of one table into its shadow tables,
+// returning nil when it is unchanged. Tables with a primary key get the keys
+// of removed rows and the new or changed rows. Tables without one, such as
+// MGDBInfo, are cleared and rewritten when they differ at all. New ImageBlob
+// rows are copied separately.
+func diffTable(db *sql.DB, table string, summary *Summary) (*tableChange, error) {
+	columns, err := sqlite.GetTableColumns(db, "result", table)
+	if err != nil {
+		return nil, err
+	}
+	columnList := quoteNames(columnNames(columns))
+	quotedTable := sqlite.QuoteIdent(table)
+	pk := sqlite.PrimaryKey(columns)
+	change := &tableChange{table: table, clearAll: len(pk) == 0}
+
+	deletes := int64(0)
+	if change.clearAll {
+		changed := false
+		err := db.QueryRow(fmt.Sprintf(
+			"select exists (select * from result.%[1]v except select * from base.%[1]v) "+
+				"or exists (select * from base.%[1]v except select * from result.%[1]v)", quotedTable,
+		)).Scan(&changed)
+		if err != nil || !changed {
+			return nil, err
+		}
+		if err := db.QueryRow(fmt.Sprintf("select count(*) from base.%v", quotedTable)).Scan(&deletes); err != nil {
+			return nil, err
+		}
+	} else {
+		pkList := quoteNames(pk)
+		if _, err := db.Exec(fmt.Sprintf(
+			"create table main.%[1]v as select %[3]v from base.%[2]v where 0", deleteTable(table), quotedTable, pkList,
+		)); err != nil {
+			return nil, err
+		}
+		result, err := db.Exec(fmt.Sprintf(
+			"insert into main.%[1]v select %[3]v from base.%[2]v except select %[3]v from result.%[2]v",
+			deleteTable(table), quotedTable, pkList,
+		))
+		if err != nil {
+			return nil, err
+		}
+		if deletes, err = result.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+
+	upserts := int64(0)
+	// ImageBlob rows never change in place, their key is the hash of the bytes
+	if table != "ImageBlob" {
+		if _, err := db.Exec(fmt.Sprintf(
+			"create table main.%[1]v as select %[3]v from result.%[2]v where 0", upsertTable(table), quotedTable, columnList,
+		)); err != nil {
+			return nil, err
+		}
+		query := fmt.Sprintf(
+			"insert into main.%[1]v select %[3]v from result.%[2]v except select %[3]v from base.%[2]v",
+			upsertTable(table), quotedTable, columnList,
+		)
+		if change.clearAll {
+			query = fmt.Sprintf("insert into main.%[1]v select %[3]v from result.%[2]v", upsertTable(table), quotedTable, columnList)
+		}
+		result, err := db.Exec(query)
+		if err != nil {
+			return nil, err
+		}
+		if upserts, err = result.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+
+	if deletes == 0 && upserts == 0 && !change.clearAll {
+		db.Exec(fmt.Sprintf("drop table if exists main.%v", deleteTable(table)))
+		db.Exec(fmt.Sprintf("drop table if exists main.%v", upsertTable(table)))
+		return nil, nil
+	}
+	summary.Deletes[table] += int(deletes)
+	summary.Upserts[table] += int(upserts)
+	summary.Rows += int(deletes + upserts)
+	return change, nil
+}
+
+// ReadInfo loads a patch's DeltaInfo
+func ReadInfo(patchPath string) (Info, error) {
+	info := Info{}
+	db, err := sqlite.OpenMGDBFile(patchPath)
+	if err != nil {
+		return info, err
+	}
+	defer db.Close()
+	err = readInfo(db, &info)
+	return info, err
+}
+
+func readInfo(db *sql.DB, info *Info) error {
+	err := db.QueryRow(
+		"select DeltaVersion, BaseName, BaseSHA256, BaseContentHash, ResultName, ResultSHA256, ResultContentHash, CreatedDate from DeltaInfo",
+	).Scan(
+		&info.DeltaVersion,
+		&info.BaseName,
+		&info.BaseSHA256,
+		&info.BaseContentHash,
+		&info.ResultName,
+		&info.ResultSHA256,
+		&info.ResultContentHash,
+		&info.CreatedDate,
+	)
+	if err != nil {
+		return fmt.Errorf("not an MGDB patch: %w", err)
+	}
+	if info.DeltaVersion != Version {
+		return fmt.Errorf("unsupported patch version %v", info.DeltaVersion)
+	}
+	return nil
+}
+
+// VerifyBase checks an MGDB against the patch base, by file hash then content hash
+func VerifyBase(info Info, basePath string) error {
+	sha, err := rdb.FileSHA256(basePath)
+	if err != nil {
+		return err
+	}
+	if sha == info.BaseSHA256 {
+		return nil
+	}
+	hash, err := contentHash(basePath)
+	if err != nil {
+		return err
+	}
+	if hash != info.BaseContentHash {
+		return fmt.Errorf("%w: %v is not %v", ErrBaseMismatch, basePath, info.BaseName)
+	}
+	return nil
+}
+
+func copyFile(src string, dst *os.File) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(dst, file)
+	return err
+}
+
+// Apply patches basePath into outPath, which may be basePath itself. The
+// patch is applied to a temporary copy that only replaces outPath once its
+// content hash matches the release. Indexed ROMs follow their game to its new
+// GameID by ExternalID, ROMs whose game is gone or has no ExternalID become
+// unmatched until indexmgdb runs again.
+func Apply(basePath string, patchPath string, outPath string) (*Summary, error) {
+	patch, err := sqlite.OpenMGDBFile(patchPath)
+	if err != nil {
+		return nil, err
+	}
+	defer patch.Close()
+	info := Info{}
+	if err := readInfo(patch, &info); err != nil {
+		return nil, err
+	}
+	summary := newSummary(info)
+	if err := VerifyBase(info, basePath); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), filepath.Base(outPath)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+	err = copyFile(basePath, tmp)
+	tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyTo(tmpPath, patch, patchPath, summary); err != nil {
+		return nil, err
+	}
+	hash, err := contentHash(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	if hash != info.ResultContentHash {
+		return nil, fmt.Errorf("%w: %v", ErrResultMismatch, info.ResultName)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return nil, err
+	}
+	return summary, os.Rename(tmpPath, outPath)
+}
+
+func applyTo(dbPath string, patch *sql.DB, patchPath string, summary *Summary) error {
+	db, err := sqlite.OpenMGDBForUpdate(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err := attach(db, patchPath, "patch"); err != nil {
+		return err
+	}
+	// Media packs have no Game table to index
+	indexed, err := sqlite.HasTable(db, "IndexedRom")
+	if err != nil {
+		return err
+	}
+	changes, err := readChanges(db)
+	if err != nil {
+		return err
+	}
+	gameCollection := "0"
+	if indexed {
+		merged, err := sqlite.HasColumn(db, "Game", "CollectionID")
+		if err != nil {
+			return err
+		}
+		if merged {
+			gameCollection = "g.CollectionID"
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	matched := 0
+	if indexed {
+		// GameIDs are positional, remember which game each ROM was matched to
+		if _, err := tx.Exec(fmt.Sprintf(
+			"create temp table IndexedGame as select r.Path, g.ExternalID, %v as CollectionID "+
+				"from main.IndexedRom r join main.Game g on g.GameID = r.GameID "+
+				"where r.GameID != 0 and g.ExternalID != ''", gameCollection,
+		)); err != nil {
+			return err
+		}
+		if err := tx.QueryRow("select count(*) from main.IndexedRom where GameID != 0").Scan(&matched); err != nil {
+			return err
+		}
+	}
+	for _, change := range changes {
+		for _, statement := range change.statements {
+			result, err := tx.Exec(statement.sql)
+			if err != nil {
+				return fmt.Errorf("unable to patch %v: %w", change.table, err)
+			}
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if statement.delete {
+				summary.Deletes[change.table] += int(rows)
+			} else {
+				summary.Upserts[change.table] += int(rows)
+			}
+			summary.Rows += int(rows)
+		}
+	}
+
+	result, err := tx.Exec("insert or ignore into main.ImageBlob (Hash, Bytes) select Hash, Bytes from patch.ImageBlob")
+	if err != nil {
+		return err
+	}
+	blobs, _ := result.RowsAffected()
+	summary.Blobs = int(blobs)
+	if indexed {
+		if _, err := tx.Exec(fmt.Sprintf(
+			"update main.IndexedRom set GameID = coalesce(("+
+				"select min(g.GameID) from main.Game g join temp.IndexedGame i "+
+				"on i.ExternalID = g.ExternalID and i.CollectionID = %v "+
+				"where i.Path = IndexedRom.Path and g.GameID != 0), 0)", gameCollection,
+		)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"update main.Game set IsIndexed = 0; " +
+				"update main.Game set IsIndexed = 1 where GameID in (select GameID from main.IndexedRom) and GameID != 0",
+		); err != nil {
+			return err
+		}
+		stillMatched := 0
+		if err := tx.QueryRow(
+			"select count(*), coalesce(sum(GameID != 0), 0) from main.IndexedRom",
+		).Scan(&summary.IndexedRoms, &stillMatched); err != nil {
+			return err
+		}
+		summary.LostRoms = matched - stillMatched
+		if _, err := tx.Exec("drop table temp.IndexedGame"); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if _, err := db.Exec("detach database patch"); err != nil {
+		return err
+	}
+	_, err = db.Exec("vacuum")
+	return err
+}
+
+type patchStatement struct {
+	sql    string
+	delete bool
+}
+
+type plannedChange struct {
+	table      string
+	statements []patchStatement
+}
+
+// readChanges turns the patch's TableChange rows into statements. Tables and
+// columns are named from the MGDB being patched, the patch only has to match
+// them, and rows are copied from its shadow tables so no patch data ends up
+// in the SQL.
+func readChanges(db *sql.DB) ([]plannedChange, error) {
+	changes := []plannedChange{}
+	tableNames, err := sqlite.GetTableNames(db, "main")
+	if err != nil {
+		return changes, err
+	}
+	tables := make(map[string]bool)
+	for _, table := range tableNames {
+		tables[table] = !sqlite.LocalTables[table]
+	}
+	patchNames, err := sqlite.GetTableNames(db, "patch")
+	if err != nil {
+		return changes, err
+	}
+	patchTables := make(map[string]bool)
+	for _, table := range patchNames {
+		patchTables[table] = true
+	}
+
+	rows, err := db.Query("select TableName, ClearAll from patch.TableChange order by Seq")
+	if err != nil {
+		return changes, err
+	}
+	defer rows.Close()
+	type change struct {
+		table    string
+		clearAll bool
+	}
+	listed := []change{}
+	for rows.Next() {
+		c := change{}
+		if err := rows.Scan(&c.table, &c.clearAll); err != nil {
+			return changes, err
+		}
+		listed = append(listed, c)
+	}
+	if err := rows.Err(); err != nil {
+		return changes, err
+	}
+	rows.Close()
+
+	for _, c := range listed {
+		if !tables[c.table] {
+			return changes, fmt.Errorf("%w: %v is not a release table", ErrBadPatch, c.table)
+		}
+		columns, err := sqlite.GetTableColumns(db, "main", c.table)
+		if err != nil {
+			return changes, err
+		}
+		names := columnNames(columns)
+		pk := sqlite.PrimaryKey(columns)
+		quotedTable := sqlite.QuoteIdent(c.table)
+		planned := plannedChange{table: c.table}
+
+		if c.clearAll {
+			planned.statements = append(planned.statements, patchStatement{
+				sql: fmt.Sprintf("delete from main.%v", quotedTable), delete: true,
+			})
+		} else if patchTables["Delete_"+c.table] {
+			if len(pk) == 0 {
+				return changes, fmt.Errorf("%w: %v has no primary key", ErrBadPatch, c.table)
+			}
+			if err := checkShadow(db, "Delete_"+c.table, pk); err != nil {
+				return changes, err
+			}
+			planned.statements = append(planned.statements, patchStatement{
+				sql: fmt.Sprintf(
+					"delete from main.%[1]v where (%[3]v) in (select %[3]v from patch.%[2]v)",
+					quotedTable, deleteTable(c.table), quoteNames(pk),
+				),
+				delete: true,
+			})
+		}
+		if patchTables["Upsert_"+c.table] {
+			if err := checkShadow(db, "Upsert_"+c.table, names); err != nil {
+				return changes, err
+			}
+			planned.statements = append(planned.statements, patchStatement{
+				sql: fmt.Sprintf(
+					"insert or replace into main.%[1]v (%[3]v) select %[3]v from patch.%[2]v",
+					quotedTable, upsertTable(c.table), quoteNames(names),
+				),
+			})
+		}
+		changes = append(changes, planned)
+	}
+	return changes, nil
+}
+
+// checkShadow makes sure a patch shadow table has exactly the expected columns
+func checkShadow(db *sql.DB, shadow string, names []string) error {
+	columns, err := sqlite.GetTableColumns(db, "patch", shadow)
+	if err != nil {
+		return err
+	}
+	shadowNames := columnNames(columns)
+	if strings.Join(shadowNames, "\x00") != strings.Join(names, "\x00") {
+		return fmt.Errorf("%w: %v has columns %v, expected %v", ErrBadPatch, shadow, shadowNames, names)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AttractMode romlist columns, see attract.cfg documentation
+var attractModeHeader = []string{
+	"Name", "Title", "Emulator", "CloneOf", "Year", "Manufacturer", "Category",
+	"Players", "Rotation", "Control", "Status", "DisplayCount", "DisplayType",
+	"AltRomname", "AltTitle", "Extra", "Buttons", "Series", "Language", "Region", "Rating",
+}
+
+// Romlist fields are ; separated, one line each
+func attractModeField(value string) string {
+	value = strings.ReplaceAll(value, ";", ",")
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// AttractModeEmulator names the emulator after the MGDB games folder
+func AttractModeEmulator(collection *Collection) string {
+	name := collection.Info.GamesFolder
+	if name == "" {
+		name = collection.Info.CollectionName
+	}
+	return strings.NewReplacer("/", "-", "\\", "-", ";", "-").Replace(name)
+}
+
+// WriteAttractMode writes an AttractMode romlist and emulator config with
+// per ROM artwork (snap, title) and overview text under outDir/{emulator}.
+// AttractMode has no developer column, so developer goes to Extra. Romlists
+// and artwork name ROMs by file name only, so of same-named files in
+// different folders only the first is listed.
+func WriteAttractMode(collection *Collection, outDir string) (int, error) {
+	outDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return 0, err
+	}
+	emulator := AttractModeEmulator(collection)
+	snapDir := filepath.Join(outDir, emulator, "snap")
+	titleDir := filepath.Join(outDir, emulator, "title")
+	overviewDir := filepath.Join(outDir, emulator, "overview")
+
+	lines := []string{"#" + strings.Join(attractModeHeader, ";")}
+	romDirs := make(map[string]bool)
+	exts := make(map[string]bool)
+	names := make(map[string]bool) // [rom.FileName]listed
+	count := 0
+	for _, game := range collection.IndexedGames() {
+		year := ""
+		if len(game.ReleaseDate) >= 4 {
+			year = game.ReleaseDate[0:4]
+		}
+		manufacturer := game.Publisher
+		if manufacturer == "" {
+			manufacturer = game.Developer
+		}
+
+		for _, rom := range game.Roms {
+			if names[rom.FileName] {
+				continue
+			}
+			names[rom.FileName] = true
+			romDirs[filepath.Dir(rom.Path)] = true
+			exts[rom.FileExt] = true
+
+			if _, err := collection.WriteImageAs(game.ScreenshotHash, snapDir, rom.FileName); err != nil {
+				return count, err
+			}
+			if _, err := collection.WriteImageAs(game.TitleScreenHash, titleDir, rom.FileName); err != nil {
+				return count, err
+			}
+			if game.Description != "" {
+				if err := os.MkdirAll(overviewDir, os.ModePerm); err != nil {
+					return count, err
+				}
+				overviewPath := filepath.Join(overviewDir, rom.FileName+".txt")
+				if err := os.WriteFile(overviewPath, []byte(game.Description), 0644); err != nil {
+					return count, err
+				}
+			}
+
+			fields := map[string]string{
+				"Name":         rom.FileName,
+				"Title":        game.Name,
+				"Emulator":     emulator,
+				"Year":         year,
+				"Manufacturer": manufacturer,
+				"Category":     game.Genre,
+				"Players":      game.Players,
+				"Extra":        game.Developer,
+				"Rating":       game.Rating,
+			}
+			values := make([]string, len(attractModeHeader))
+			for i, column := range attractModeHeader {
+				values[i] = attractModeField(fields[column])
+			}
+			lines = append(lines, strings.Join(values, ";"))
+			count++
+		}
+	}
+
+	romlistDir := filepath.Join(outDir, "romlists")
+	if err := os.MkdirAll(romlistDir, os.ModePerm); err != nil {
+		return count, err
+	}
+	romlistPath := filepath.Join(romlistDir, emulator+".txt")
+	if err := os.WriteFile(romlistPath, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return count, fmt.Errorf("unable to write %v: %w", romlistPath, err)
+	}
+
+	emulatorDir := filepath.Join(outDir, "emulators")
+	if err := os.MkdirAll(emulatorDir, os.ModePerm); err != nil {
+		return count, err
+	}
+	cfg := fmt.Sprintf(
+		"# Generated from MGDB %v\nexecutable\nargs\n"+
+			"rompath              %v\nromext               %v\nsystem               %v\n"+
+			"artwork    snap            %v\nartwork    title           %v\n",
+		collection.Info.CollectionName,
+		strings.Join(sortedKeys(romDirs), ";"),
+		strings.Join(sortedKeys(exts), ";"),
+		collection.Info.CollectionName,
+		snapDir,
+		titleDir,
+	)
+	cfgPath := filepath.Join(emulatorDir, emulator+".cfg")
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0644); err != nil {
+		return count, fmt.Errorf("unable to write %v: %w", cfgPath, err)
+	}
+	return count, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,259 @@
+package export
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+//go:embed templates/*.html
+var catalogTemplates embed.FS
+
+type catalogGame struct {
+	Game
+	Year        string
+	Screenshot  string // relative to the game page
+	TitleScreen string
+	KnownRoms   []mgdb.RomTag
+}
+
+var catalogKindLabels = map[string]string{"genre": "Genre", "developer": "Developer", "publisher": "Publisher"}
+
+// Games sharing a genre, developer or publisher
+type catalogGroup struct {
+	Kind  string
+	ID    int
+	Label string
+	Games []*catalogGame
+}
+
+type catalogGroups struct {
+	Label  string
+	Groups []*catalogGroup
+}
+
+type catalogSystem struct {
+	ID               string
+	Info             mgdb.MGDBInfo
+	Games            []*catalogGame
+	Groups           []catalogGroups
+	ScreenshotCount  int
+	TitleScreenCount int
+}
+
+type catalogPage struct {
+	Title   string
+	Root    string // relative path to the catalog root
+	System  *catalogSystem
+	Systems []*catalogSystem
+	Group   *catalogGroup
+	Game    *catalogGame
+	List    []catalogListRow
+}
+
+// Rows of the games table, with links relative to the current page
+type catalogListRow struct {
+	*catalogGame
+	Prefix string
+}
+
+func listRows(prefix string, games []*catalogGame) []catalogListRow {
+	rows := make([]catalogListRow, len(games))
+	for i, game := range games {
+		rows[i] = catalogListRow{catalogGame: game, Prefix: prefix}
+	}
+	return rows
+}
+
+func loadCatalogTemplates() (map[string]*template.Template, error) {
+	pages := make(map[string]*template.Template)
+	for _, name := range []string{"index", "system", "group", "game"} {
+		tmpl, err := template.ParseFS(catalogTemplates, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, err
+		}
+		pages[name] = tmpl
+	}
+	return pages, nil
+}
+
+func renderPage(pages map[string]*template.Template, name string, path string, page catalogPage) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := pages[name].ExecuteTemplate(file, name+".html", page); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to render %v: %w", path, err)
+	}
+	return file.Close()
+}
+
+func groupGames(kind string, games []*catalogGame, key func(*catalogGame) (int, string)) []*catalogGroup {
+	byID := make(map[int]*catalogGroup)
+	groups := []*catalogGroup{}
+	for _, game := range games {
+		id, label := key(game)
+		if label == "" {
+			continue
+		}
+		group, ok := byID[id]
+		if !ok {
+			group = &catalogGroup{Kind: kind, ID: id, Label: label}
+			byID[id] = group
+			groups = append(groups, group)
+		}
+		group.Games = append(group.Games, game)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.ToLower(groups[i].Label) < strings.ToLower(groups[j].Label)
+	})
+	return groups
+}
+
+// loadCatalogSystem extracts images to {systemDir}/images and resolves
+// known RDB ROMs per game. The ~Unknown loose ROM game is left out.
+func loadCatalogSystem(collection *Collection, id string, systemDir string) (*catalogSystem, error) {
+	system := &catalogSystem{ID: id, Info: collection.Info}
+	imageDir := filepath.Join(systemDir, "images")
+	imageRel := func(path string) string {
+		if path == "" {
+			return ""
+		}
+		return "../images/" + filepath.Base(path)
+	}
+
+	for _, game := range collection.Games {
+		if game.GameID == 0 {
+			continue
+		}
+		screenshot, err := collection.WriteImage(game.ScreenshotHash, imageDir)
+		if err != nil {
+			return nil, err
+		}
+		titleScreen, err := collection.WriteImage(game.TitleScreenHash, imageDir)
+		if err != nil {
+			return nil, err
+		}
+		knownRoms, err := sqlite.GetGameRomTags(collection.db, game.GameID)
+		if err != nil {
+			return nil, err
+		}
+		year := ""
+		if len(game.ReleaseDate) >= 4 {
+			year = game.ReleaseDate[0:4]
+		}
+		if screenshot != "" {
+			system.ScreenshotCount++
+		}
+		if titleScreen != "" {
+			system.TitleScreenCount++
+		}
+		system.Games = append(system.Games, &catalogGame{
+			Game:        game,
+			Year:        year,
+			Screenshot:  imageRel(screenshot),
+			TitleScreen: imageRel(titleScreen),
+			KnownRoms:   knownRoms,
+		})
+	}
+	sort.SliceStable(system.Games, func(i, j int) bool {
+		return strings.ToLower(system.Games[i].Name) < strings.ToLower(system.Games[j].Name)
+	})
+
+	system.Groups = []catalogGroups{
+		{Label: "Genres", Groups: groupGames("genre", system.Games, func(game *catalogGame) (int, string) {
+			return game.GenreID, game.Genre
+		})},
+		{Label: "Developers", Groups: groupGames("developer", system.Games, func(game *catalogGame) (int, string) {
+			return game.DeveloperID, game.Developer
+		})},
+		{Label: "Publishers", Groups: groupGames("publisher", system.Games, func(game *catalogGame) (int, string) {
+			return game.PublisherID, game.Publisher
+		})},
+	}
+	return system, nil
+}
+
+// CatalogID names a collection's folder in the catalog, by games folder
+func CatalogID(info mgdb.MGDBInfo, used map[string]bool) string {
+	id := info.GamesFolder
+	if id == "" {
+		id = info.CollectionName
+	}
+	id = strings.NewReplacer("/", "-", "\\", "-", " ", "_").Replace(id)
+	base := id
+	for n := 2; used[id]; n++ {
+		id = fmt.Sprintf("%v-%v", base, n)
+	}
+	used[id] = true
+	return id
+}
+
+// WriteCatalog renders a static HTML catalog of the collections to outDir:
+// an index of systems, then per system an index, genre, developer and
+// publisher pages, a page per game and the extracted images.
+func WriteCatalog(collections []*Collection, outDir string) (int, error) {
+	pages, err := loadCatalogTemplates()
+	if err != nil {
+		return 0, err
+	}
+
+	systems := []*catalogSystem{}
+	used := make(map[string]bool)
+	count := 0
+	for _, collection := range collections {
+		id := CatalogID(collection.Info, used)
+		systemDir := filepath.Join(outDir, id)
+		system, err := loadCatalogSystem(collection, id, systemDir)
+		if err != nil {
+			return count, err
+		}
+		systems = append(systems, system)
+
+		err = renderPage(pages, "system", filepath.Join(systemDir, "index.html"), catalogPage{
+			Title: system.Info.CollectionName, Root: "../", System: system, List: listRows("", system.Games),
+		})
+		if err != nil {
+			return count, err
+		}
+		for _, groups := range system.Groups {
+			for _, group := range groups.Groups {
+				err := renderPage(pages, "group", filepath.Join(systemDir, group.Kind, fmt.Sprintf("%v.html", group.ID)), catalogPage{
+					Title:  fmt.Sprintf("%v: %v", catalogKindLabels[group.Kind], group.Label),
+					Root:   "../../",
+					System: system,
+					Group:  group,
+					List:   listRows("../", group.Games),
+				})
+				if err != nil {
+					return count, err
+				}
+			}
+		}
+		for _, game := range system.Games {
+			err := renderPage(pages, "game", filepath.Join(systemDir, "game", fmt.Sprintf("%v.html", game.GameID)), catalogPage{
+				Title: game.Name, Root: "../../", System: system, Game: game,
+			})
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+
+	err = renderPage(pages, "index", filepath.Join(outDir, "index.html"), catalogPage{
+		Title: "MiSTer Games Catalog", Root: "", Systems: systems,
+	})
+	return count, err
+}
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+// Collection is an MGDB resolved for front-end export,
+// lookup IDs replaced by names and indexed ROMs grouped per game
+type Collection struct {
+	Info  mgdb.MGDBInfo
+	Games []Game
+	db    *sql.DB
+}
+
+type Game struct {
+	mgdb.Game
+	Genre     string
+	Developer string
+	Publisher string
+	Roms      []mgdb.IndexedRom
+}
+
+// Load reads all games. Games without indexed ROMs are kept with empty Roms,
+// writers decide whether to include them.
+func Load(db *sql.DB) (*Collection, error) {
+	info, err := sqlite.GetMGDBInfo(db)
+	if err != nil {
+		return nil, err
+	}
+	collection := &Collection{Info: info, db: db}
+
+	genres := make(map[int]string)
+	genreRows, err := sqlite.GetGenres(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, genre := range genreRows {
+		genres[genre.GenreID] = genre.Name
+	}
+	developers := make(map[int]string)
+	developerRows, err := sqlite.GetDevelopers(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, developer := range developerRows {
+		developers[developer.DeveloperID] = developer.Name
+	}
+	publishers := make(map[int]string)
+	publisherRows, err := sqlite.GetPublishers(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, publisher := range publisherRows {
+		publishers[publisher.PublisherID] = publisher.Name
+	}
+
+	roms := make(map[int][]mgdb.IndexedRom)
+	romRows, err := sqlite.GetIndexedRoms(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, rom := range romRows {
+		roms[rom.GameID] = append(roms[rom.GameID], rom)
+	}
+
+	games, err := sqlite.GetGames(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, game := range games {
+		collection.Games = append(collection.Games, Game{
+			Game:      game,
+			Genre:     knownName(genres[game.GenreID]),
+			Developer: knownName(developers[game.DeveloperID]),
+			Publisher: knownName(publishers[game.PublisherID]),
+			Roms:      roms[game.GameID],
+		})
+	}
+	return collection, nil
+}
+
+// ~Unknown placeholders carry no metadata worth exporting
+func knownName(name string) string {
+	if name == "~Unknown" {
+		return ""
+	}
+	return name
+}
+
+// IndexedGames skips loose ROMs under GameID 0 and games with no local files
+func (collection *Collection) IndexedGames() []Game {
+	games := []Game{}
+	for _, game := range collection.Games {
+		if game.GameID != 0 && len(game.Roms) > 0 {
+			games = append(games, game)
+		}
+	}
+	return games
+}
+
+// ImageExt guesses a file extension from image bytes
+func ImageExt(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/bmp":
+		return ".bmp"
+	}
+	return ".png"
+}
+
+// WriteImage extracts an ImageBlob to dir/{hash}.{ext} once and returns the path.
+// Empty hashes and blobs missing from the MGDB return an empty path.
+func (collection *Collection) WriteImage(hash string, dir string) (string, error) {
+	return collection.WriteImageAs(hash, dir, hash)
+}
+
+// WriteImageAs extracts an ImageBlob to dir/{name}.{ext}, for front-ends
+// that find artwork by ROM name
+func (collection *Collection) WriteImageAs(hash string, dir string, name string) (string, error) {
+	if hash == "" {
+		return "", nil
+	}
+	blob, err := sqlite.GetImageBlob(collection.db, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	imgPath := filepath.Join(dir, name+ImageExt(blob.Bytes))
+	if _, err := os.Stat(imgPath); err == nil && name == hash {
+		return imgPath, nil
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(imgPath, blob.Bytes, 0644); err != nil {
+		return "", fmt.Errorf("unable to write image %v: %w", imgPath, err)
+	}
+	return imgPath, nil
+}
+
+// RelPath makes front-end paths relative to the metadata file's folder
+// with a ./ prefix, paths outside it stay absolute
+func RelPath(base string, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil || strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) {
+		return filepath.ToSlash(path)
+	}
+	return "./" + filepath.ToSlash(rel)
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/gamelist"
+)
+
+// ESReleaseDate converts MGDB YYYY-MM-DD dates to EmulationStation's YYYYMMDDT000000
+func ESReleaseDate(date string) string {
+	date = strings.ReplaceAll(date, "-", "")
+	if len(date) < 8 {
+		return ""
+	}
+	return date[0:8] + "T000000"
+}
+
+// WriteGamelist writes outDir/gamelist.xml with one entry per indexed ROM,
+// extracting screenshots and title screens to outDir/media/images.
+// Screenshot maps to <image> and title screen to <thumbnail>, mirroring buildmgdb.
+func WriteGamelist(collection *Collection, outDir string) (int, error) {
+	outDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return 0, err
+	}
+	mediaDir := filepath.Join(outDir, "media", "images")
+
+	gl := &gamelist.Gamelist{
+		Provider: gamelist.Provider{
+			System:           collection.Info.CollectionName,
+			Software:         "MiSTer_Games_Data_Utils",
+			Database:         "MGDB " + collection.Info.MGDBVersion,
+			MisterCollection: collection.Info.CollectionName,
+			MisterSystemIds:  collection.Info.SupportedSystemIds,
+		},
+	}
+
+	for _, game := range collection.IndexedGames() {
+		screenshot, err := collection.WriteImage(game.ScreenshotHash, mediaDir)
+		if err != nil {
+			return 0, err
+		}
+		titleScreen, err := collection.WriteImage(game.TitleScreenHash, mediaDir)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, rom := range game.Roms {
+			entry := gamelist.Game{
+				ID:          game.ExternalID,
+				Source:      "MGDB",
+				Path:        RelPath(outDir, rom.Path),
+				Name:        game.Name,
+				Desc:        game.Description,
+				Rating:      game.Rating,
+				ReleaseDate: ESReleaseDate(game.ReleaseDate),
+				Developer:   game.Developer,
+				Publisher:   game.Publisher,
+				Genre:       game.Genre,
+				Players:     game.Players,
+			}
+			if screenshot != "" {
+				entry.Image = RelPath(outDir, screenshot)
+			}
+			if titleScreen != "" {
+				entry.Thumbnail = RelPath(outDir, titleScreen)
+			}
+			gl.Games = append(gl.Games, entry)
+		}
+	}
+
+	data, err := gamelist.MarshalGamelist(gl)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return 0, err
+	}
+	gamelistPath := filepath.Join(outDir, "gamelist.xml")
+	if err := os.WriteFile(gamelistPath, data, 0644); err != nil {
+		return 0, fmt.Errorf("unable to write %v: %w", gamelistPath, err)
+	}
+	return len(gl.Games), nil
+}
@@ -0,0 +1,121 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/gamelist"
+)
+
+// pegasusValue indents continuation lines. Pegasus joins those with spaces,
+// so every line break is kept as a "." line.
+func pegasusValue(value string) string {
+	lines := []string{}
+	for i, line := range strings.Split(strings.TrimSpace(strings.ReplaceAll(value, "\r\n", "\n")), "\n") {
+		if i > 0 {
+			lines = append(lines, ".")
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n  ")
+}
+
+func writePegasusField(builder *strings.Builder, key string, value string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	fmt.Fprintf(builder, "%v: %v\n", key, pegasusValue(value))
+}
+
+// PegasusRating converts a 0-1 ES rating to Pegasus percentages
+func PegasusRating(rating string) string {
+	value, err := strconv.ParseFloat(rating, 64)
+	if err != nil || value <= 0 {
+		return ""
+	}
+	if value <= 1 {
+		value *= 100
+	}
+	return fmt.Sprintf("%.0f%%", value)
+}
+
+// WritePegasus writes outDir/metadata.pegasus.txt for Pegasus frontend,
+// one game entry per MGDB game listing all its indexed files
+func WritePegasus(collection *Collection, outDir string) (int, error) {
+	outDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return 0, err
+	}
+	mediaDir := filepath.Join(outDir, "media", "images")
+
+	builder := &strings.Builder{}
+	writePegasusField(builder, "collection", collection.Info.CollectionName)
+	writePegasusField(builder, "shortname", strings.ToLower(collection.Info.GamesFolder))
+	writePegasusField(builder, "summary", collection.Info.Description)
+
+	exts := make(map[string]bool)
+	games := collection.IndexedGames()
+	for _, game := range games {
+		for _, rom := range game.Roms {
+			exts[strings.TrimPrefix(strings.ToLower(rom.FileExt), ".")] = true
+		}
+	}
+	extList := []string{}
+	for ext := range exts {
+		extList = append(extList, ext)
+	}
+	sort.Strings(extList)
+	writePegasusField(builder, "extensions", strings.Join(extList, ", "))
+
+	for _, game := range games {
+		screenshot, err := collection.WriteImage(game.ScreenshotHash, mediaDir)
+		if err != nil {
+			return 0, err
+		}
+		titleScreen, err := collection.WriteImage(game.TitleScreenHash, mediaDir)
+		if err != nil {
+			return 0, err
+		}
+
+		builder.WriteString("\n")
+		writePegasusField(builder, "game", game.Name)
+		if len(game.Roms) == 1 {
+			writePegasusField(builder, "file", RelPath(outDir, game.Roms[0].Path))
+		} else {
+			files := make([]string, len(game.Roms))
+			for i, rom := range game.Roms {
+				files[i] = RelPath(outDir, rom.Path)
+			}
+			builder.WriteString("files:\n  " + strings.Join(files, "\n  ") + "\n")
+		}
+		writePegasusField(builder, "developer", game.Developer)
+		writePegasusField(builder, "publisher", game.Publisher)
+		writePegasusField(builder, "genre", game.Genre)
+		writePegasusField(builder, "players", game.Players)
+		writePegasusField(builder, "rating", PegasusRating(game.Rating))
+		writePegasusField(builder, "release", game.ReleaseDate)
+		writePegasusField(builder, "description", game.Description)
+		if screenshot != "" {
+			writePegasusField(builder, "assets.screenshot", RelPath(outDir, screenshot))
+		}
+		if titleScreen != "" {
+			writePegasusField(builder, "assets.titlescreen", RelPath(outDir, titleScreen))
+		}
+		writePegasusField(builder, gamelist.PegasusIDKey, game.ExternalID)
+	}
+
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return 0, err
+	}
+	metadataPath := filepath.Join(outDir, "metadata.pegasus.txt")
+	if err := os.WriteFile(metadataPath, []byte(builder.String()), 0644); err != nil {
+		return 0, fmt.Errorf("unable to write %v: %w", metadataPath, err)
+	}
+	return len(games), nil
+}
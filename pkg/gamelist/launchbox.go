@@ -0,0 +1,158 @@
+package gamelist
+
+import (
+	"encoding/xml"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LaunchBox reads corePath/Platforms/*.xml with media from
+// corePath/Images/{Platform}/{Image Type}/{Title}-01.{ext}
+type LaunchBox struct{}
+
+type launchBoxFile struct {
+	XMLName xml.Name        `xml:"LaunchBox"`
+	Games   []launchBoxGame `xml:"Game"`
+}
+
+type launchBoxGame struct {
+	ID                  string `xml:"ID"`
+	DatabaseID          string `xml:"DatabaseID"`
+	ApplicationPath     string `xml:"ApplicationPath"`
+	Title               string `xml:"Title"`
+	Notes               string `xml:"Notes"`
+	ReleaseDate         string `xml:"ReleaseDate"`
+	Developer           string `xml:"Developer"`
+	Publisher           string `xml:"Publisher"`
+	Genre               string `xml:"Genre"`
+	MaxPlayers          string `xml:"MaxPlayers"`
+	CommunityStarRating string `xml:"CommunityStarRating"`
+	StarRating          string `xml:"StarRating"`
+	Platform            string `xml:"Platform"`
+}
+
+// LaunchBox image folders by gamelist field, in order of preference
+var (
+	launchBoxScreenshots  = []string{"Screenshot - Gameplay", "Screenshot"}
+	launchBoxTitleScreens = []string{"Screenshot - Game Title"}
+)
+
+func (LaunchBox) Name() string {
+	return "LaunchBox"
+}
+
+func (LaunchBox) Detect(corePath string) bool {
+	return len(sortedGlob(filepath.Join(corePath, "Platforms", "*.xml"))) > 0
+}
+
+func (LaunchBox) Load(corePath string) (*Gamelist, error) {
+	gamelist := &Gamelist{Provider: Provider{Software: "LaunchBox"}}
+	for _, platformPath := range sortedGlob(filepath.Join(corePath, "Platforms", "*.xml")) {
+		data, err := os.ReadFile(platformPath)
+		if err != nil {
+			return nil, err
+		}
+		platform := launchBoxFile{}
+		if err := xml.Unmarshal(data, &platform); err != nil {
+			return nil, err
+		}
+		platformName := strings.TrimSuffix(filepath.Base(platformPath), filepath.Ext(platformPath))
+		if gamelist.Provider.System == "" {
+			gamelist.Provider.System = platformName
+		}
+		images := launchBoxImagesIn(filepath.Join(corePath, "Images", platformName))
+
+		for _, lbGame := range platform.Games {
+			rating, _ := strconv.ParseFloat(lbGame.CommunityStarRating, 64)
+			if userRating, err := strconv.ParseFloat(lbGame.StarRating, 64); err == nil && userRating > 0 {
+				rating = userRating
+			}
+			players := ""
+			if maxPlayers, err := strconv.Atoi(lbGame.MaxPlayers); err == nil && maxPlayers > 0 {
+				players = "1"
+				if maxPlayers > 1 {
+					players = "1-" + lbGame.MaxPlayers
+				}
+			}
+			genre, _, _ := strings.Cut(lbGame.Genre, ";")
+			title := launchBoxFileTitle(lbGame.Title)
+
+			gamelist.Games = append(gamelist.Games, Game{
+				ID:          lbGame.DatabaseID,
+				Source:      "LaunchBox",
+				Path:        strings.ReplaceAll(lbGame.ApplicationPath, "\\", "/"),
+				Name:        lbGame.Title,
+				Desc:        lbGame.Notes,
+				Rating:      ESRating(rating, 5),
+				ReleaseDate: ESDate(lbGame.ReleaseDate),
+				Developer:   lbGame.Developer,
+				Publisher:   lbGame.Publisher,
+				Genre:       strings.TrimSpace(genre),
+				Players:     players,
+				Image:       corePathRel(corePath, images.find(launchBoxScreenshots, title)),
+				Thumbnail:   corePathRel(corePath, images.find(launchBoxTitleScreens, title)),
+			})
+		}
+	}
+	assignIDs(gamelist.Games)
+	return gamelist, nil
+}
+
+// LaunchBox swaps characters not allowed in filenames for underscores
+func launchBoxFileTitle(title string) string {
+	return strings.NewReplacer(
+		":", "_", "'", "_", "/", "_", "\\", "_", "?", "_",
+		"*", "_", "\"", "_", "<", "_", ">", "_", "|", "_", "#", "_",
+	).Replace(title)
+}
+
+// launchBoxImages indexes image files by type folder, region subfolders included
+type launchBoxImages map[string][]string // [image type]paths
+
+func launchBoxImagesIn(platformImages string) launchBoxImages {
+	images := make(launchBoxImages)
+	entries, err := os.ReadDir(platformImages)
+	if err != nil {
+		return images
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		imageType := entry.Name()
+		filepath.WalkDir(filepath.Join(platformImages, imageType), func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				images[imageType] = append(images[imageType], path)
+			}
+			return nil
+		})
+	}
+	return images
+}
+
+// find returns the first "{title}-NN.ext" image of the preferred types
+func (images launchBoxImages) find(imageTypes []string, title string) string {
+	for _, imageType := range imageTypes {
+		best := ""
+		for _, path := range images[imageType] {
+			base := filepath.Base(path)
+			base = strings.TrimSuffix(base, filepath.Ext(base))
+			if !strings.HasPrefix(base, title+"-") {
+				continue
+			}
+			if _, err := strconv.Atoi(strings.TrimPrefix(base, title+"-")); err != nil {
+				continue
+			}
+			if best == "" || path < best {
+				best = path
+			}
+		}
+		if best != "" {
+			return best
+		}
+	}
+	return ""
+}
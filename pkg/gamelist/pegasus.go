@@ -0,0 +1,201 @@
+package gamelist
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PegasusIDKey is the custom metadata key holding the gamelist ID,
+// written by exportmgdb so exported metadata can be rebuilt into an MGDB
+const PegasusIDKey = "x-mgdb-externalid"
+
+// Pegasus reads Pegasus frontend metadata files in corePath, with assets
+// relative to the metadata file
+type Pegasus struct{}
+
+type pegasusField struct {
+	key    string
+	values []string
+}
+
+func (Pegasus) Name() string {
+	return "Pegasus"
+}
+
+func pegasusFiles(corePath string) []string {
+	files := []string{}
+	for _, name := range []string{"metadata.pegasus.txt", "metadata.txt"} {
+		if isFile(filepath.Join(corePath, name)) {
+			files = append(files, filepath.Join(corePath, name))
+		}
+	}
+	return append(files, sortedGlob(filepath.Join(corePath, "*.metadata.pegasus.txt"))...)
+}
+
+func (Pegasus) Detect(corePath string) bool {
+	return len(pegasusFiles(corePath)) > 0
+}
+
+func (Pegasus) Load(corePath string) (*Gamelist, error) {
+	gamelist := &Gamelist{Provider: Provider{Software: "Pegasus"}}
+	for _, metadataPath := range pegasusFiles(corePath) {
+		entries, err := parsePegasus(metadataPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry[0].key == "collection" {
+				if gamelist.Provider.System == "" {
+					gamelist.Provider.System = strings.Join(entry[0].values, " ")
+				}
+				continue
+			}
+			gamelist.Games = append(gamelist.Games, pegasusGames(corePath, filepath.Dir(metadataPath), entry)...)
+		}
+	}
+	assignIDs(gamelist.Games)
+	return gamelist, nil
+}
+
+// parsePegasus splits a metadata file into collection and game entries.
+// Indented lines continue the previous value, a lone "." is a blank line.
+func parsePegasus(path string) ([][]pegasusField, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := [][]pegasusField{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(entries) == 0 {
+				continue
+			}
+			entry := entries[len(entries)-1]
+			value := strings.TrimSpace(line)
+			if value == "." {
+				value = ""
+			}
+			entry[len(entry)-1].values = append(entry[len(entry)-1].values, value)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field := pegasusField{key: strings.ToLower(strings.TrimSpace(key)), values: []string{}}
+		if value = strings.TrimSpace(value); value != "" {
+			field.values = append(field.values, value)
+		}
+		if field.key == "game" || field.key == "collection" {
+			entries = append(entries, []pegasusField{field})
+		} else if len(entries) > 0 {
+			entries[len(entries)-1] = append(entries[len(entries)-1], field)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Text values join continuation lines with spaces, blank lines break paragraphs
+func pegasusText(values []string) string {
+	text := ""
+	for _, value := range values {
+		if value == "" {
+			text += "\n"
+		} else if text == "" || strings.HasSuffix(text, "\n") {
+			text += value
+		} else {
+			text += " " + value
+		}
+	}
+	return text
+}
+
+// Pegasus ratings are percentages or 0-1 fractions
+func pegasusRating(rating string) string {
+	if strings.HasSuffix(rating, "%") {
+		value, _ := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(rating, "%")), 64)
+		return ESRating(value, 100)
+	}
+	value, _ := strconv.ParseFloat(rating, 64)
+	return ESRating(value, 1)
+}
+
+// pegasusGames returns one record per file of a game entry, sharing its ID,
+// so every listed ROM gets a slug for the same game
+func pegasusGames(corePath string, metadataDir string, entry []pegasusField) []Game {
+	game := Game{Source: "Pegasus", Name: strings.Join(entry[0].values, " ")}
+	files := []string{}
+	first := func(field pegasusField) string {
+		if len(field.values) == 0 {
+			return ""
+		}
+		return field.values[0]
+	}
+	asset := func(field pegasusField) string {
+		path := first(field)
+		if path == "" {
+			return ""
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(metadataDir, path)
+		}
+		return corePathRel(corePath, path)
+	}
+
+	for _, field := range entry[1:] {
+		switch field.key {
+		case "file", "files":
+			for _, file := range field.values {
+				if file != "" {
+					files = append(files, file)
+				}
+			}
+		case "developer", "developers":
+			game.Developer = first(field)
+		case "publisher", "publishers":
+			game.Publisher = first(field)
+		case "genre", "genres":
+			game.Genre = first(field)
+		case "players":
+			game.Players = first(field)
+		case "rating":
+			game.Rating = pegasusRating(first(field))
+		case "release":
+			game.ReleaseDate = ESDate(first(field))
+		case "description":
+			game.Desc = pegasusText(field.values)
+		case "summary":
+			if game.Desc == "" {
+				game.Desc = pegasusText(field.values)
+			}
+		case "assets.screenshot", "assets.screenshots":
+			game.Image = asset(field)
+		case "assets.titlescreen":
+			game.Thumbnail = asset(field)
+		case PegasusIDKey, "x-id":
+			game.ID = first(field)
+		}
+	}
+
+	if len(files) == 0 {
+		return []Game{game}
+	}
+	games := []Game{}
+	for _, file := range files {
+		game.Path = file
+		games = append(games, game)
+	}
+	return games
+}
@@ -0,0 +1,165 @@
+package gamelist
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Source reads scraper output in a core folder into gamelist records.
+// Image and Thumbnail paths are relative to the core folder, ReleaseDate
+// uses EmulationStation's YYYYMMDDT000000 and ID is a numeric game ID.
+type Source interface {
+	Name() string
+	Detect(corePath string) bool
+	Load(corePath string) (*Gamelist, error)
+}
+
+// Sources in detection order
+var Sources = []Source{EmulationStation{}, LaunchBox{}, Pegasus{}}
+
+var ErrNoSource = errors.New("no gamelist source found")
+
+// LoadSource loads the first detected source in corePath
+func LoadSource(corePath string) (*Gamelist, Source, error) {
+	for _, source := range Sources {
+		if !source.Detect(corePath) {
+			continue
+		}
+		gamelist, err := source.Load(corePath)
+		if err != nil {
+			return nil, source, fmt.Errorf("unable to load %v source: %w", source.Name(), err)
+		}
+		return gamelist, source, nil
+	}
+	return nil, nil, fmt.Errorf("%w in %v", ErrNoSource, corePath)
+}
+
+// EmulationStation reads corePath/gamelist.xml as scraped by Skraper
+type EmulationStation struct{}
+
+func (EmulationStation) Name() string {
+	return "EmulationStation"
+}
+
+func (EmulationStation) Detect(corePath string) bool {
+	return isFile(filepath.Join(corePath, "gamelist.xml"))
+}
+
+func (EmulationStation) Load(corePath string) (*Gamelist, error) {
+	data, err := os.ReadFile(filepath.Join(corePath, "gamelist.xml"))
+	if err != nil {
+		return nil, err
+	}
+	gamelist := &Gamelist{}
+	if err := xml.Unmarshal(data, gamelist); err != nil {
+		return nil, err
+	}
+	return gamelist, nil
+}
+
+func isFile(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && !stat.IsDir()
+}
+
+// ESDate converts YYYY, YYYY-MM and YYYY-MM-DD dates, optionally followed
+// by a time, to YYYYMMDDT000000. Missing month and day default to 01.
+func ESDate(date string) string {
+	date, _, _ = strings.Cut(strings.TrimSpace(date), "T")
+	date, _, _ = strings.Cut(date, " ")
+	parts := strings.Split(date, "-")
+	if len(parts[0]) != 4 {
+		return ""
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "01")
+	}
+	for i := 1; i < 3; i++ {
+		if len(parts[i]) == 1 {
+			parts[i] = "0" + parts[i]
+		}
+	}
+	es := parts[0] + parts[1] + parts[2]
+	if len(es) != 8 {
+		return ""
+	}
+	if _, err := strconv.Atoi(es); err != nil {
+		return ""
+	}
+	return es + "T000000"
+}
+
+// ESRating formats a rating scaled to 0-1
+func ESRating(value float64, scale float64) string {
+	if value <= 0 || scale <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(math.Round(value/scale*100)/100, 'f', -1, 64)
+}
+
+// Relative media path from corePath, "" when outside of it
+func corePathRel(corePath string, path string) string {
+	if path == "" {
+		return ""
+	}
+	if !filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	rel, err := filepath.Rel(corePath, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return rel
+}
+
+// Synthesized IDs start above the IDs scrapers hand out
+const syntheticIDBase = 1000000000
+
+// assignIDs keeps numeric IDs as they are, duplicates included, since
+// region variants sharing an ID are one game to buildmgdb. Games without
+// one get an ID hashed from their title, so it survives rebuilds and
+// reordering, and untitled variants of a game share it too.
+func assignIDs(games []Game) {
+	owners := make(map[int]string) // [id]title key, "" for scraped IDs
+	for _, game := range games {
+		if id, err := strconv.Atoi(game.ID); err == nil && id > 0 {
+			owners[id] = ""
+		}
+	}
+	for i, game := range games {
+		if id, err := strconv.Atoi(game.ID); err == nil && id > 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(game.Name))
+		if key == "" {
+			key = strings.ToLower(filepath.Base(game.Path))
+		}
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		id := syntheticIDBase + int(hash.Sum32()%syntheticIDBase)
+		// Probe past IDs taken by other titles
+		for {
+			owner, taken := owners[id]
+			if !taken || owner == key {
+				break
+			}
+			id++
+		}
+		owners[id] = key
+		games[i].ID = strconv.Itoa(id)
+	}
+}
+
+func sortedGlob(pattern string) []string {
+	matches, _ := filepath.Glob(pattern)
+	sort.Strings(matches)
+	return matches
+}
@@ -12,30 +12,30 @@ type Gamelist struct {
 
 type Provider struct {
 	//XMLName  xml.Name `xml:"provider"`
-	System           string `xml:"System"`
-	Software         string `xml:"software"`
-	Database         string `xml:"database"`
-	Web              string `xml:"web"`
-	MisterCollection string `xml:"misterguicollection"`
-	MisterSystemIds  string `xml:"misterguisystemids"`
+	System           string `xml:"System,omitempty"`
+	Software         string `xml:"software,omitempty"`
+	Database         string `xml:"database,omitempty"`
+	Web              string `xml:"web,omitempty"`
+	MisterCollection string `xml:"misterguicollection,omitempty"`
+	MisterSystemIds  string `xml:"misterguisystemids,omitempty"`
 }
 
 type Game struct {
 	//XMLName     xml.Name `xml:"game"`
-	ID          string `xml:"id,attr"`
-	Source      string `xml:"source,attr"`
+	ID          string `xml:"id,attr,omitempty"`
+	Source      string `xml:"source,attr,omitempty"`
 	Path        string `xml:"path"`
 	Name        string `xml:"name"`
-	Desc        string `xml:"desc"`
-	Rating      string `xml:"rating"`
-	ReleaseDate string `xml:"releasedate"`
-	Developer   string `xml:"developer"`
-	Publisher   string `xml:"publisher"`
-	Genre       string `xml:"genre"`
-	Players     string `xml:"players"`
-	Image       string `xml:"image"`
-	Thumbnail   string `xml:"thumbnail"`
-	GenreID     string `xml:"genreid"`
+	Desc        string `xml:"desc,omitempty"`
+	Rating      string `xml:"rating,omitempty"`
+	ReleaseDate string `xml:"releasedate,omitempty"`
+	Developer   string `xml:"developer,omitempty"`
+	Publisher   string `xml:"publisher,omitempty"`
+	Genre       string `xml:"genre,omitempty"`
+	Players     string `xml:"players,omitempty"`
+	Image       string `xml:"image,omitempty"`
+	Thumbnail   string `xml:"thumbnail,omitempty"`
+	GenreID     string `xml:"genreid,omitempty"`
 }
 
 func ParseGamelist(data []byte) *Gamelist {
@@ -46,3 +46,11 @@ func ParseGamelist(data []byte) *Gamelist {
 	}
 	return gamelist
 }
+
+func MarshalGamelist(gamelist *Gamelist) ([]byte, error) {
+	data, err := xml.MarshalIndent(gamelist, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
@@ -0,0 +1,187 @@
+package indexer
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mister"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/overrides"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/romname"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+// Files above this size are matched by slug only, hashing CD images on
+// the MiSTer ARM chip is too slow to be worth it
+const DefaultMaxCrcSize int64 = 64 * 1024 * 1024
+
+// Indexer maps local game files to MGDB games, in order of precedence:
+// curated overrides, RomCrc by file CRC32, then SlugRom by filename slug.
+// Unmatched files are indexed as loose ROMs under GameID 0.
+type Indexer struct {
+	Convention romname.Convention
+	Overrides  *overrides.Overrides
+	MaxCrcSize int64
+	Applied    []overrides.Applied // overrides used, for the index report
+
+	slugs       map[string]int    // slug:GameID
+	crcs        map[string]string // crc32:slug
+	externalIDs map[string]int    // ExternalID:GameID
+}
+
+func newIndexer(convention romname.Convention, ovr *overrides.Overrides) *Indexer {
+	if ovr == nil {
+		ovr = overrides.New()
+	}
+	return &Indexer{
+		Convention:  convention,
+		Overrides:   ovr,
+		MaxCrcSize:  DefaultMaxCrcSize,
+		slugs:       make(map[string]int),
+		crcs:        make(map[string]string),
+		externalIDs: make(map[string]int),
+		Applied:     []overrides.Applied{},
+	}
+}
+
+func (idx *Indexer) load(slugRoms []mgdb.SlugRom, romCrcs []mgdb.RomCrc) {
+	for _, slugRom := range slugRoms {
+		idx.slugs[slugRom.Slug] = slugRom.GameID
+	}
+	for _, romCrc := range romCrcs {
+		idx.crcs[strings.ToLower(romCrc.CRC32)] = romCrc.Slug
+	}
+}
+
+// New loads the mappings of a single system MGDB. Merged MGDBs reuse slugs
+// and CRCs across collections, index them with NewForCollection.
+func New(db *sql.DB, convention romname.Convention, ovr *overrides.Overrides) (*Indexer, error) {
+	idx := newIndexer(convention, ovr)
+	if merged, err := sqlite.IsMerged(db); err != nil {
+		return idx, err
+	} else if merged {
+		return idx, fmt.Errorf("merged MGDB, index each collection with NewForCollection")
+	}
+
+	slugRoms, err := sqlite.GetSlugRoms(db)
+	if err != nil {
+		return idx, err
+	}
+	romCrcs, err := sqlite.GetRomCrcs(db)
+	if err != nil {
+		return idx, err
+	}
+	idx.load(slugRoms, romCrcs)
+
+	games, err := sqlite.GetGames(db)
+	if err != nil {
+		return idx, err
+	}
+	for _, game := range games {
+		if game.ExternalID != "" {
+			idx.externalIDs[game.ExternalID] = game.GameID
+		}
+	}
+	return idx, nil
+}
+
+// NewForCollection loads the mappings of one collection in a merged MGDB,
+// so files only match games of their own system
+func NewForCollection(db *sql.DB, collectionID int, convention romname.Convention, ovr *overrides.Overrides) (*Indexer, error) {
+	idx := newIndexer(convention, ovr)
+	slugRoms, err := sqlite.GetCollectionSlugRoms(db, collectionID)
+	if err != nil {
+		return idx, err
+	}
+	romCrcs, err := sqlite.GetCollectionRomCrcs(db, collectionID)
+	if err != nil {
+		return idx, err
+	}
+	idx.load(slugRoms, romCrcs)
+	idx.externalIDs, err = sqlite.GetCollectionExternalIDs(db, collectionID)
+	return idx, err
+}
+
+// FileCRC32 hashes a file as lowercase hex, matching RDB crc values
+func FileCRC32(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%08x", hash.Sum32()), nil
+}
+
+func (idx *Indexer) fileCRC32(path string) string {
+	stat, err := os.Stat(path)
+	if err != nil || stat.Size() > idx.MaxCrcSize || strings.EqualFold(filepath.Ext(path), ".zip") {
+		return ""
+	}
+	crc, err := FileCRC32(path)
+	if err != nil {
+		fmt.Println("Unable to hash", path, err)
+		return ""
+	}
+	return crc
+}
+
+// Match resolves a scanned file to an IndexedRom row
+func (idx *Indexer) Match(file mister.ScannedFile) mgdb.IndexedRom {
+	fileBase := filepath.Base(file.Path)
+	fileExt := filepath.Ext(fileBase)
+	rom := mgdb.IndexedRom{
+		Path:               file.Path,
+		FileName:           strings.TrimSuffix(fileBase, fileExt),
+		FileExt:            fileExt,
+		GameID:             0,
+		SupportedSystemIds: file.System.Id,
+	}
+	slug := romname.Slugify(fileBase, idx.Convention)
+
+	// Only hash when a CRC could change the outcome
+	crc := ""
+	if len(idx.crcs) > 0 || len(idx.Overrides.Crc) > 0 {
+		crc = idx.fileCRC32(file.Path)
+	}
+
+	if applied, ok := idx.Overrides.Match(fileBase, crc, slug); ok {
+		if gameID, ok := idx.externalIDs[applied.ExternalID]; ok {
+			applied.Detail = file.Path
+			idx.Applied = append(idx.Applied, applied)
+			rom.GameID = gameID
+			return rom
+		}
+		fmt.Printf("Override %v %v: unknown game ID %v, skipping\n", applied.Kind, applied.Key, applied.ExternalID)
+	}
+
+	if crcSlug, ok := idx.crcs[crc]; ok && crc != "" {
+		if gameID, ok := idx.slugs[crcSlug]; ok {
+			rom.GameID = gameID
+			return rom
+		}
+	}
+	if gameID, ok := idx.slugs[slug]; ok {
+		rom.GameID = gameID
+	}
+	return rom
+}
+
+// Index matches all files, GameID 0 marks loose ROMs
+func (idx *Indexer) Index(files []mister.ScannedFile) []mgdb.IndexedRom {
+	roms := make([]mgdb.IndexedRom, 0, len(files))
+	for _, file := range files {
+		rom := idx.Match(file)
+		fmt.Printf("Indexed %v -> %v\n", rom.Path, rom.GameID)
+		roms = append(roms, rom)
+	}
+	return roms
+}
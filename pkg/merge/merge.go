@@ -0,0 +1,413 @@
+package merge
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+// UnknownName is the ID 0 placeholder every MGDB lookup table carries
+const UnknownName = "~Unknown"
+
+// Merged MGDBs list their sources in Collection and tag rows with a
+// CollectionID. Slugs and CRCs are only unique within a system, so SlugRom,
+// RomCrc and RomTag are keyed by collection too.
+const mergedSchema = `
+create table Collection (
+	CollectionID integer primary key not null,
+	CollectionName text not null,
+	GamesFolder text not null,
+	SupportedSystemIds text not null,
+	BuildDate text not null,
+	MGDBVersion text not null,
+	RdbRevision text not null,
+	RdbSHA256 text not null,
+	GameCount integer not null
+);
+
+alter table Game add column CollectionID integer not null default 0;
+CREATE INDEX game_collection_idx ON Game (CollectionID);
+
+drop table SlugRom;
+create table SlugRom (
+	Slug text not null,
+	GameID integer not null,
+	SupportedSystemIds text not null,
+	CollectionID integer not null,
+	primary key (CollectionID, Slug)
+);
+
+drop table RomCrc;
+create table RomCrc (
+	CRC32 text not null,
+	Slug text not null,
+	CollectionID integer not null,
+	primary key (CollectionID, CRC32)
+);
+CREATE INDEX romcrc_crc_idx ON RomCrc (CRC32);
+
+drop table RomTag;
+create table RomTag (
+	RomName text not null,
+	CRC32 text not null,
+	Title text not null,
+	Regions text not null,
+	Languages text not null,
+	Revision text not null,
+	Version text not null,
+	IsBeta integer not null,
+	IsProto integer not null,
+	IsDemo integer not null,
+	IsUnlicensed integer not null,
+	IsPirate integer not null,
+	IsHack integer not null,
+	Translation text not null,
+	IsVerified integer not null,
+	IsBadDump integer not null,
+	IsAlternate integer not null,
+	Extra text not null,
+	CollectionID integer not null,
+	primary key (CollectionID, CRC32, RomName)
+);
+CREATE INDEX romtag_romname_idx ON RomTag (RomName);
+
+create temp table IDMap (
+	Kind text not null,
+	OldID integer not null,
+	NewID integer not null,
+	primary key (Kind, OldID)
+);`
+
+const gameColumns = "Name, IsIndexed, Description, Rating, ReleaseDate, Players, ExternalID, ScreenshotHash, TitleScreenHash"
+
+const romTagColumns = "RomName, CRC32, Title, Regions, Languages, Revision, Version, " +
+	"IsBeta, IsProto, IsDemo, IsUnlicensed, IsPirate, IsHack, Translation, IsVerified, IsBadDump, IsAlternate, Extra"
+
+// Collection is one source MGDB in the merge
+type Collection struct {
+	CollectionID int
+	Path         string
+	Info         mgdb.MGDBInfo
+	Games        int
+	GameOffset   int // added to source GameIDs, GameID 0 stays 0
+	Blobs        int
+	NewBlobs     int // blobs not already merged from an earlier collection
+
+	genres     []mgdb.Genre
+	developers []mgdb.Developer
+	publishers []mgdb.Publisher
+}
+
+type Summary struct {
+	Info        mgdb.MGDBInfo
+	Collections []*Collection
+	Games       int
+	Genres      int
+	Developers  int
+	Publishers  int
+	Blobs       int
+}
+
+// Names from all collections, case-insensitively unified and renumbered
+// alphabetically. The ~Unknown placeholder keeps ID 0.
+type nameIDs struct {
+	names map[string]string // lowercase:first spelling seen
+	ids   map[string]int    // lowercase:merged ID
+}
+
+func newNameIDs() *nameIDs {
+	return &nameIDs{names: make(map[string]string), ids: make(map[string]int)}
+}
+
+func nameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func (n *nameIDs) add(name string) {
+	key := nameKey(name)
+	if _, ok := n.names[key]; !ok && key != nameKey(UnknownName) {
+		n.names[key] = strings.TrimSpace(name)
+	}
+}
+
+func (n *nameIDs) number() []string {
+	keys := make([]string, 0, len(n.names))
+	for key := range n.names {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	names := []string{UnknownName}
+	n.ids[nameKey(UnknownName)] = 0
+	for i, key := range keys {
+		n.ids[key] = i + 1
+		names = append(names, n.names[key])
+	}
+	return names
+}
+
+func (n *nameIDs) id(name string) int {
+	return n.ids[nameKey(name)]
+}
+
+func loadCollection(path string) (*Collection, error) {
+	if sqlite.IsMediaPath(path) {
+		return nil, errors.New("a media pack, merge its MGDB instead")
+	}
+	db, err := sqlite.OpenMGDB(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	collection := &Collection{Path: path}
+	if collection.Info, err = sqlite.GetMGDBInfo(db); err != nil {
+		return nil, err
+	}
+	if ok, err := sqlite.HasColumn(db, "Game", "CollectionID"); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, errors.New("already a merged MGDB")
+	}
+	if collection.genres, err = sqlite.GetGenres(db); err != nil {
+		return nil, err
+	}
+	if collection.developers, err = sqlite.GetDevelopers(db); err != nil {
+		return nil, err
+	}
+	if collection.publishers, err = sqlite.GetPublishers(db); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// Info for the merged MGDB, revision fields only when all sources agree
+func mergedInfo(name string, collections []*Collection) mgdb.MGDBInfo {
+	info := mgdb.MGDBInfo{
+		CollectionName: name,
+		BuildDate:      time.Now().Format("2006-01-02"),
+		MGDBVersion:    collections[0].Info.MGDBVersion,
+		Description:    collections[0].Info.Description,
+		RdbRevision:    collections[0].Info.RdbRevision,
+	}
+	systems := []string{}
+	seen := make(map[string]bool)
+	for _, collection := range collections {
+		for _, system := range strings.Split(collection.Info.SupportedSystemIds, ",") {
+			if system != "" && !seen[system] {
+				seen[system] = true
+				systems = append(systems, system)
+			}
+		}
+		if collection.Info.RdbRevision != info.RdbRevision {
+			info.RdbRevision = ""
+		}
+	}
+	info.SupportedSystemIds = strings.Join(systems, ",")
+	return info
+}
+
+// MGDBs combines per system MGDBs into one at outPath, remapping
+// GameID, GenreID, DeveloperID and PublisherID. Genres and companies with the
+// same name across systems share an ID and ImageBlob rows are deduped by hash.
+func MGDBs(paths []string, outPath string, name string) (*Summary, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no MGDBs to merge")
+	}
+	genres, developers, publishers := newNameIDs(), newNameIDs(), newNameIDs()
+	collections := []*Collection{}
+	for i, path := range paths {
+		collection, err := loadCollection(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %v: %w", path, err)
+		}
+		collection.CollectionID = i + 1
+		for _, genre := range collection.genres {
+			genres.add(genre.Name)
+		}
+		for _, developer := range collection.developers {
+			developers.add(developer.Name)
+		}
+		for _, publisher := range collection.publishers {
+			publishers.add(publisher.Name)
+		}
+		collections = append(collections, collection)
+	}
+
+	summary := &Summary{Info: mergedInfo(name, collections), Collections: collections}
+	if err := os.Remove(outPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	db, err := sqlite.CreateMGDB(outPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	// attached schemas and the temp IDMap are per connection
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(mergedSchema); err != nil {
+		return nil, err
+	}
+
+	sqlite.InsertMGDBInfo(db, summary.Info)
+	genreRows := []mgdb.Genre{}
+	for id, name := range genres.number() {
+		genreRows = append(genreRows, mgdb.Genre{GenreID: id, Name: name})
+	}
+	sqlite.BulkInsertGenres(db, genreRows)
+	developerRows := []mgdb.Developer{}
+	for id, name := range developers.number() {
+		developerRows = append(developerRows, mgdb.Developer{DeveloperID: id, Name: name})
+	}
+	sqlite.BulkInsertDevelopers(db, developerRows)
+	publisherRows := []mgdb.Publisher{}
+	for id, name := range publishers.number() {
+		publisherRows = append(publisherRows, mgdb.Publisher{PublisherID: id, Name: name})
+	}
+	sqlite.BulkInsertPublishers(db, publisherRows)
+	summary.Genres = len(genreRows) - 1
+	summary.Developers = len(developerRows) - 1
+	summary.Publishers = len(publisherRows) - 1
+
+	// Loose ROMs of every collection share the GameID 0 placeholder
+	if _, err := db.Exec(
+		"insert into Game (GameID, Name, IsIndexed, GenreID, Description, Rating, ReleaseDate, " +
+			"DeveloperID, PublisherID, Players, ExternalID) values (0, '~Unknown', 0, 0, '', '', '', 0, 0, '', '')",
+	); err != nil {
+		return nil, err
+	}
+
+	offset := 0
+	for _, collection := range collections {
+		collection.GameOffset = offset
+		if err := mergeCollection(db, collection, genres, developers, publishers); err != nil {
+			return nil, fmt.Errorf("unable to merge %v: %w", collection.Path, err)
+		}
+		if err := db.QueryRow("select coalesce(max(GameID), 0) from Game").Scan(&offset); err != nil {
+			return nil, err
+		}
+		summary.Games += collection.Games
+	}
+	if err := db.QueryRow("select count(*) from ImageBlob").Scan(&summary.Blobs); err != nil {
+		return nil, err
+	}
+	sqlite.Vacuum(db)
+	return summary, nil
+}
+
+func mergeCollection(db *sql.DB, collection *Collection, genres *nameIDs, developers *nameIDs, publishers *nameIDs) error {
+	if _, err := db.Exec("attach database ? as src", collection.Path); err != nil {
+		return err
+	}
+	defer db.Exec("detach database src")
+	// A split source keeps its images in the media pack next to it
+	blobSource := "select Hash, Bytes from src.ImageBlob"
+	mediaPath := sqlite.MediaPath(collection.Path)
+	if _, err := os.Stat(mediaPath); err == nil {
+		if _, err := db.Exec("attach database ? as srcmedia", mediaPath); err != nil {
+			return err
+		}
+		defer db.Exec("detach database srcmedia")
+		blobSource += " union select Hash, Bytes from srcmedia.ImageBlob"
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("delete from temp.IDMap"); err != nil {
+		return err
+	}
+	idMap := [][3]any{}
+	for _, genre := range collection.genres {
+		idMap = append(idMap, [3]any{"Genre", genre.GenreID, genres.id(genre.Name)})
+	}
+	for _, developer := range collection.developers {
+		idMap = append(idMap, [3]any{"Developer", developer.DeveloperID, developers.id(developer.Name)})
+	}
+	for _, publisher := range collection.publishers {
+		idMap = append(idMap, [3]any{"Publisher", publisher.PublisherID, publishers.id(publisher.Name)})
+	}
+	for _, row := range idMap {
+		if _, err := tx.Exec("insert into temp.IDMap (Kind, OldID, NewID) values (?, ?, ?)", row[:]...); err != nil {
+			return err
+		}
+	}
+
+	info := collection.Info
+	result, err := tx.Exec(
+		"insert into Game (GameID, GenreID, DeveloperID, PublisherID, CollectionID, "+gameColumns+") "+
+			"select g.GameID + ?, "+
+			"coalesce((select NewID from temp.IDMap where Kind = 'Genre' and OldID = g.GenreID), 0), "+
+			"coalesce((select NewID from temp.IDMap where Kind = 'Developer' and OldID = g.DeveloperID), 0), "+
+			"coalesce((select NewID from temp.IDMap where Kind = 'Publisher' and OldID = g.PublisherID), 0), "+
+			"?, "+gameColumns+" from src.Game g where g.GameID != 0",
+		collection.GameOffset, collection.CollectionID,
+	)
+	if err != nil {
+		return err
+	}
+	games, _ := result.RowsAffected()
+	collection.Games = int(games)
+
+	gameID := "case when GameID = 0 then 0 else GameID + ? end"
+	if _, err := tx.Exec(
+		"insert into SlugRom (Slug, GameID, SupportedSystemIds, CollectionID) "+
+			"select Slug, "+gameID+", SupportedSystemIds, ? from src.SlugRom",
+		collection.GameOffset, collection.CollectionID,
+	); err != nil {
+		return err
+	}
+	// Index paths are unique per MiSTer, usually empty in release builds
+	if _, err := tx.Exec(
+		"insert or ignore into IndexedRom (Path, FileName, FileExt, GameID, SupportedSystemIds) "+
+			"select Path, FileName, FileExt, "+gameID+", SupportedSystemIds from src.IndexedRom",
+		collection.GameOffset,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"insert into RomCrc (CRC32, Slug, CollectionID) select CRC32, Slug, ? from src.RomCrc", collection.CollectionID,
+	); err != nil {
+		return err
+	}
+	hasRomTag := 0
+	if err := tx.QueryRow("select count(*) from src.sqlite_master where type = 'table' and name = 'RomTag'").Scan(&hasRomTag); err != nil {
+		return err
+	}
+	if hasRomTag > 0 {
+		if _, err := tx.Exec(
+			"insert into RomTag ("+romTagColumns+", CollectionID) select "+romTagColumns+", ? from src.RomTag",
+			collection.CollectionID,
+		); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.QueryRow("select count(*) from (" + blobSource + ")").Scan(&collection.Blobs); err != nil {
+		return err
+	}
+	result, err = tx.Exec("insert or ignore into ImageBlob (Hash, Bytes) " + blobSource)
+	if err != nil {
+		return err
+	}
+	newBlobs, _ := result.RowsAffected()
+	collection.NewBlobs = int(newBlobs)
+
+	if _, err := tx.Exec(
+		"insert into Collection (CollectionID, CollectionName, GamesFolder, SupportedSystemIds, BuildDate, "+
+			"MGDBVersion, RdbRevision, RdbSHA256, GameCount) values (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		collection.CollectionID, info.CollectionName, info.GamesFolder, info.SupportedSystemIds, info.BuildDate,
+		info.MGDBVersion, info.RdbRevision, info.RdbSHA256, collection.Games,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -7,6 +7,8 @@ type MGDBInfo struct {
 	BuildDate          string
 	MGDBVersion        string
 	Description        string
+	RdbRevision        string // libretro-database commit the RDB was fetched at
+	RdbSHA256          string
 }
 
 type Game struct {
@@ -36,6 +38,28 @@ type RomCrc struct {
 	Slug  string
 }
 
+// Parsed No-Intro/TOSEC filename tags per RDB ROM
+type RomTag struct {
+	RomName      string
+	CRC32        string
+	Title        string
+	Regions      string
+	Languages    string
+	Revision     string
+	Version      string
+	IsBeta       int
+	IsProto      int
+	IsDemo       int
+	IsUnlicensed int
+	IsPirate     int
+	IsHack       int
+	Translation  string
+	IsVerified   int
+	IsBadDump    int
+	IsAlternate  int
+	Extra        string
+}
+
 type IndexedRom struct {
 	Path               string
 	FileName           string
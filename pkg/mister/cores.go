@@ -0,0 +1,88 @@
+package mister
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CoreOverrides let users pick the core per system or per game, e.g. a fork
+// or one of System.AltRbf. Values are rbf paths like "_Console/GBA2P" or
+// just the core name "GBA2P" when it is a declared Rbf or AltRbf.
+//
+//	{
+//		"systems": {"GBA": "GBA2P"},
+//		"games": {"/media/fat/games/Genesis/Sonic.md": "_Console/MegaDrive_fork"}
+//	}
+//
+// Game keys match the full game path first, then the bare filename.
+type CoreOverrides struct {
+	Systems map[string]string `json:"systems"`
+	Games   map[string]string `json:"games"`
+}
+
+// LoadCoreOverrides reads a user core override file.
+// A missing file is not an error and yields no overrides.
+func LoadCoreOverrides(path string) (CoreOverrides, error) {
+	ovr := CoreOverrides{
+		Systems: make(map[string]string),
+		Games:   make(map[string]string),
+	}
+	if path == "" {
+		return ovr, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ovr, nil
+	} else if err != nil {
+		return ovr, err
+	}
+	if err := json.Unmarshal(data, &ovr); err != nil {
+		return ovr, fmt.Errorf("unable to parse %v: %w", path, err)
+	}
+	if ovr.Systems == nil {
+		ovr.Systems = make(map[string]string)
+	}
+	if ovr.Games == nil {
+		ovr.Games = make(map[string]string)
+	}
+	return ovr, nil
+}
+
+// Rbfs lists the default core followed by declared alternates
+func (system System) Rbfs() []string {
+	rbfs := []string{}
+	if system.Rbf != "" {
+		rbfs = append(rbfs, system.Rbf)
+	}
+	return append(rbfs, system.AltRbf...)
+}
+
+// ResolveRbf expands a bare core name to a declared rbf path,
+// anything else is taken as a user supplied rbf path
+func (system System) ResolveRbf(name string) string {
+	for _, rbf := range system.Rbfs() {
+		if strings.EqualFold(rbf, name) || strings.EqualFold(filepath.Base(rbf), name) {
+			return rbf
+		}
+	}
+	return name
+}
+
+// Rbf picks the core for a game, per game override first, then per system,
+// then the system default
+func (ovr CoreOverrides) Rbf(system System, path string) string {
+	if rbf, ok := ovr.Games[path]; ok && rbf != "" {
+		return system.ResolveRbf(rbf)
+	}
+	if rbf, ok := ovr.Games[filepath.Base(path)]; ok && rbf != "" {
+		return system.ResolveRbf(rbf)
+	}
+	if rbf, ok := ovr.Systems[system.Id]; ok && rbf != "" {
+		return system.ResolveRbf(rbf)
+	}
+	return system.Rbf
+}
@@ -0,0 +1,91 @@
+package mister
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MglPathPrefix walks back from the MiSTer working directory so absolute
+// game paths resolve from /, same as mrext generated MGLs
+const MglPathPrefix = "../../../../.."
+
+// CmdInterface is the MiSTer main command pipe
+const CmdInterface = "/dev/MiSTer_cmd"
+
+// GroupMglDef finds the launch definition for a path within a CoreGroup.
+// The first system with a matching slot wins. Systems without an Rbf,
+// such as SG-1000 in the SMS group, launch with the group's first core.
+func GroupMglDef(systems []System, path string) (System, *MglParams, error) {
+	for _, system := range systems {
+		mglDef, err := PathToMglDef(system, path)
+		if err != nil || mglDef == nil {
+			continue
+		}
+		for _, primary := range systems {
+			if system.Rbf != "" {
+				break
+			}
+			system.Rbf = primary.Rbf
+			system.Id = primary.Id
+		}
+		return system, mglDef, nil
+	}
+	return System{}, nil, fmt.Errorf("no system in group has matching mgl args: %s", path)
+}
+
+// mglUnsafe can't be written raw into an MGL. MiSTer reads MGL values
+// without decoding entities, so escaping them would change the path.
+const mglUnsafe = "\"<>\r\n"
+
+// MglContents renders a .mgl launcher for a game file on the MiSTer. Values
+// are written as is, paths MiSTer can't read back are an error.
+func MglContents(rbf string, mglDef *MglParams, path string) (string, error) {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	for _, value := range []string{rbf, mglDef.Method, path} {
+		if strings.ContainsAny(value, mglUnsafe) {
+			return "", fmt.Errorf("%q has characters an MGL can't hold", value)
+		}
+	}
+	return fmt.Sprintf(
+		"<mistergamedescription>\n"+
+			"\t<rbf>%s</rbf>\n"+
+			"\t<file delay=\"%d\" type=\"%s\" index=\"%d\" path=\"%s%s\"/>\n"+
+			"</mistergamedescription>\n",
+		rbf,
+		mglDef.Delay,
+		mglDef.Method,
+		mglDef.Index,
+		MglPathPrefix,
+		path,
+	), nil
+}
+
+// LaunchGame writes a temporary MGL honoring core overrides and asks the
+// MiSTer main binary to load it. Only works on a MiSTer.
+func LaunchGame(systems []System, path string, ovr CoreOverrides) error {
+	system, mglDef, err := GroupMglDef(systems, path)
+	if err != nil {
+		return err
+	}
+
+	contents, err := MglContents(ovr.Rbf(system, path), mglDef, path)
+	if err != nil {
+		return err
+	}
+	mglPath := filepath.Join(os.TempDir(), "mgdb_launch.mgl")
+	if err := os.WriteFile(mglPath, []byte(contents), 0644); err != nil {
+		return err
+	}
+
+	cmd, err := os.OpenFile(CmdInterface, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("unable to open %v: %w", CmdInterface, err)
+	}
+	defer cmd.Close()
+	_, err = cmd.WriteString("load_core " + mglPath + "\n")
+	return err
+}
@@ -0,0 +1,150 @@
+package mister
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GamesRootCandidates are the places MiSTer setups keep games folders,
+// in the same precedence order as MiSTer main
+var GamesRootCandidates = []string{
+	"/media/usb0/games", "/media/usb0",
+	"/media/usb1/games", "/media/usb1",
+	"/media/usb2/games", "/media/usb2",
+	"/media/usb3/games", "/media/usb3",
+	"/media/usb4/games", "/media/usb4",
+	"/media/usb5/games", "/media/usb5",
+	"/media/fat/cifs/games", "/media/fat/cifs",
+	"/media/fat/games", "/media/fat",
+}
+
+// ScannedFile is a game file found under a system folder
+type ScannedFile struct {
+	Path   string
+	System System
+	Mgl    *MglParams
+}
+
+// GamesRoots keeps the candidate roots that exist as directories
+func GamesRoots(candidates []string) []string {
+	roots := []string{}
+	for _, candidate := range candidates {
+		if stat, err := os.Stat(candidate); err == nil && stat.IsDir() {
+			roots = append(roots, candidate)
+		}
+	}
+	return roots
+}
+
+// MatchExt checks a path against a slot extension. Extensions may be globs
+// such as ".s?c" or ".*", matched case-insensitively.
+func MatchExt(ext string, path string) bool {
+	ext = strings.ToLower(ext)
+	path = strings.ToLower(path)
+	if !strings.ContainsAny(ext, "*?[") {
+		return strings.HasSuffix(path, ext)
+	}
+	if !strings.HasPrefix(ext, "*") {
+		ext = "*" + ext
+	}
+	matched, err := filepath.Match(ext, filepath.Base(path))
+	return err == nil && matched
+}
+
+// FolderNames lists the folder names a system may live under, Folder then Alias
+func (system System) FolderNames() []string {
+	names := append([]string{}, system.Folder...)
+	names = append(names, system.Alias...)
+	return append(names, system.Id)
+}
+
+// Case-insensitive lookup of a relative, possibly nested, folder under root
+func resolveFolder(root string, rel string) (string, bool) {
+	current := root
+	for _, segment := range strings.Split(filepath.ToSlash(rel), "/") {
+		entries, err := os.ReadDir(current)
+		if err != nil {
+			return "", false
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.IsDir() && strings.EqualFold(entry.Name(), segment) {
+				current = filepath.Join(current, entry.Name())
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	return current, true
+}
+
+// SystemFolders resolves every folder of the given systems under each root.
+// Several systems may share one folder, such as ATARI7800 with Atari2600.
+func SystemFolders(roots []string, systems []System) map[string][]System {
+	folders := make(map[string][]System)
+	for _, root := range roots {
+		for _, system := range systems {
+			seen := make(map[string]bool)
+			for _, name := range system.FolderNames() {
+				if name == "" {
+					continue
+				}
+				folder, ok := resolveFolder(root, name)
+				if !ok || seen[folder] {
+					continue
+				}
+				seen[folder] = true
+				folders[folder] = append(folders[folder], system)
+			}
+		}
+	}
+	return folders
+}
+
+// Scan walks all system folders, nested subfolders included, and returns
+// files matching a slot of the folder's systems. First matching system wins.
+func Scan(roots []string, systems []System) ([]ScannedFile, error) {
+	files := []ScannedFile{}
+	seen := make(map[string]bool)
+	for folder, folderSystems := range SystemFolders(roots, systems) {
+		err := filepath.WalkDir(folder, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				fmt.Println("Unable to scan", path, err)
+				return nil
+			}
+			if entry.IsDir() {
+				if path != folder && strings.HasPrefix(entry.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if seen[path] || strings.HasPrefix(entry.Name(), ".") {
+				return nil
+			}
+			for _, system := range folderSystems {
+				mglDef, err := PathToMglDef(system, path)
+				if err != nil {
+					continue
+				}
+				seen[path] = true
+				files = append(files, ScannedFile{Path: path, System: system, Mgl: mglDef})
+				break
+			}
+			return nil
+		})
+		if err != nil {
+			return files, err
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
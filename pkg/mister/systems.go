@@ -6,7 +6,6 @@ package mister
 
 import (
 	"fmt"
-	s "strings"
 )
 
 const (
@@ -74,6 +73,7 @@ type System struct {
 	SetNameSameDir bool
 	Folder         []string
 	Rbf            string
+	AltRbf         []string // alternate cores able to launch the same slots
 	Slots          []Slot
 }
 
@@ -107,7 +107,7 @@ func PathToMglDef(system System, path string) (*MglParams, error) {
 
 	for _, ft := range system.Slots {
 		for _, ext := range ft.Exts {
-			if s.HasSuffix(s.ToLower(path), ext) {
+			if MatchExt(ext, path) {
 				return ft.Mgl, nil
 			}
 		}
@@ -117,11 +117,9 @@ func PathToMglDef(system System, path string) (*MglParams, error) {
 }
 
 // FIXME: launch game > launch new game same system > not working? should it?
-// TODO: alternate cores (user core override)
 // TODO: alternate arcade folders
 // TODO: custom scan function
 // TODO: custom launch function
-// TODO: support globbing on extensions
 
 var Systems = map[string]System{
 	// Consoles
@@ -435,6 +433,7 @@ var Systems = map[string]System{
 		Alias:        []string{"GB"},
 		Folder:       []string{"GAMEBOY"},
 		Rbf:          "_Console/Gameboy",
+		AltRbf:       []string{"_Console/Gameboy2P"},
 		Slots: []Slot{
 			{
 				Exts: []string{".gb"},
@@ -456,6 +455,7 @@ var Systems = map[string]System{
 		Folder:       []string{"GAMEBOY", "GBC"},
 		SetName:      "GBC",
 		Rbf:          "_Console/Gameboy",
+		AltRbf:       []string{"_Console/Gameboy2P"},
 		Slots: []Slot{
 			{
 				Exts: []string{".gbc"},
@@ -536,6 +536,7 @@ var Systems = map[string]System{
 		Alias:        []string{"GameboyAdvance"},
 		Folder:       []string{"GBA"},
 		Rbf:          "_Console/GBA",
+		AltRbf:       []string{"_Console/GBA2P"},
 		Slots: []Slot{
 			{
 				Exts: []string{".gba"},
@@ -575,6 +576,7 @@ var Systems = map[string]System{
 		Alias:        []string{"MegaDrive"},
 		Folder:       []string{"MegaDrive", "Genesis"},
 		Rbf:          "_Console/MegaDrive",
+		AltRbf:       []string{"_Console/Genesis"},
 		Slots: []Slot{
 			{
 				Exts: []string{".bin", ".gen", ".md"},
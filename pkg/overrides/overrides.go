@@ -0,0 +1,153 @@
+package overrides
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Overrides pin ROMs to a game when slug matching gets it wrong.
+// Values are gamelist game IDs (mgdb.Game.ExternalID) rather than MGDB GameIDs,
+// which are reindexed on every build and not stable across rebuilds.
+//
+// Stored per core as cores/{ScrapeFolder}/overrides.json:
+//
+//	{
+//		"crc":      {"d445f698": "1234"},
+//		"slug":     {"tetris": "1234"},
+//		"filename": {"Tetris (Japan) (En) (Hack).sfc": "1234"}
+//	}
+type Overrides struct {
+	Crc      map[string]string `json:"crc"`
+	Slug     map[string]string `json:"slug"`
+	Filename map[string]string `json:"filename"`
+}
+
+const (
+	KindCrc      = "crc"
+	KindSlug     = "slug"
+	KindFilename = "filename"
+)
+
+// Applied records a single override that changed a mapping, for build reports
+type Applied struct {
+	Kind       string `json:"kind"`
+	Key        string `json:"key"`
+	ExternalID string `json:"external_id"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+func (a Applied) String() string {
+	if a.Detail == "" {
+		return fmt.Sprintf("%v %v -> %v", a.Kind, a.Key, a.ExternalID)
+	}
+	return fmt.Sprintf("%v %v -> %v (%v)", a.Kind, a.Key, a.ExternalID, a.Detail)
+}
+
+func New() *Overrides {
+	return &Overrides{
+		Crc:      make(map[string]string),
+		Slug:     make(map[string]string),
+		Filename: make(map[string]string),
+	}
+}
+
+// Load reads overrides.json from the core path.
+// A missing file is not an error and yields empty overrides.
+func Load(corePath string) (*Overrides, error) {
+	return LoadFile(filepath.Join(corePath, "overrides.json"))
+}
+
+func LoadFile(path string) (*Overrides, error) {
+	ovr := New()
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ovr, nil
+	} else if err != nil {
+		return ovr, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return ovr, err
+	}
+	if err := json.Unmarshal(data, ovr); err != nil {
+		return ovr, fmt.Errorf("unable to parse %v: %w", path, err)
+	}
+	ovr.normalize()
+	return ovr, nil
+}
+
+// CRCs are compared lowercase, RDB and file hashes vary in case
+func (ovr *Overrides) normalize() {
+	if ovr.Crc == nil {
+		ovr.Crc = make(map[string]string)
+	}
+	if ovr.Slug == nil {
+		ovr.Slug = make(map[string]string)
+	}
+	if ovr.Filename == nil {
+		ovr.Filename = make(map[string]string)
+	}
+	crcs := make(map[string]string, len(ovr.Crc))
+	for crc, externalID := range ovr.Crc {
+		crcs[strings.ToLower(crc)] = externalID
+	}
+	ovr.Crc = crcs
+}
+
+func (ovr *Overrides) Len() int {
+	return len(ovr.Crc) + len(ovr.Slug) + len(ovr.Filename)
+}
+
+func (ovr *Overrides) ByCrc(crc string) (string, bool) {
+	externalID, ok := ovr.Crc[strings.ToLower(crc)]
+	return externalID, ok
+}
+
+func (ovr *Overrides) BySlug(slug string) (string, bool) {
+	externalID, ok := ovr.Slug[slug]
+	return externalID, ok
+}
+
+func (ovr *Overrides) ByFilename(filename string) (string, bool) {
+	externalID, ok := ovr.Filename[filename]
+	return externalID, ok
+}
+
+// Match resolves a single ROM, most specific key first: filename, CRC, slug.
+// Any key may be empty if unknown to the caller.
+func (ovr *Overrides) Match(filename string, crc string, slug string) (Applied, bool) {
+	if filename != "" {
+		if externalID, ok := ovr.ByFilename(filename); ok {
+			return Applied{Kind: KindFilename, Key: filename, ExternalID: externalID}, true
+		}
+	}
+	if crc != "" {
+		if externalID, ok := ovr.ByCrc(crc); ok {
+			return Applied{Kind: KindCrc, Key: strings.ToLower(crc), ExternalID: externalID}, true
+		}
+	}
+	if slug != "" {
+		if externalID, ok := ovr.BySlug(slug); ok {
+			return Applied{Kind: KindSlug, Key: slug, ExternalID: externalID}, true
+		}
+	}
+	return Applied{}, false
+}
+
+// SortApplied orders a report by kind then key for stable output
+func SortApplied(applied []Applied) {
+	sort.Slice(applied, func(i, j int) bool {
+		if applied[i].Kind != applied[j].Kind {
+			return applied[i].Kind < applied[j].Kind
+		}
+		return applied[i].Key < applied[j].Key
+	})
+}
@@ -0,0 +1,376 @@
+package rdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotModified means the existing RDB matches upstream
+var ErrNotModified = errors.New("rdb not modified")
+
+// ErrManifestMismatch means a fetched RDB differs from its manifest entry
+var ErrManifestMismatch = errors.New("rdb does not match manifest")
+
+// ErrKeptExisting means no mirror could provide an RDB and the one already
+// on disk, still matching the manifest, was kept
+var ErrKeptExisting = errors.New("no mirror available, kept existing rdb")
+
+// ManifestEntry is the expected size and hash of an RDB file
+type ManifestEntry struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest pins RDB files by name. Fetched files must match their entry,
+// files without an entry are accepted as is. With a Revision it is the
+// lock file of a libretro-database commit, Ref is what the user asked for.
+type Manifest struct {
+	Ref      string                   `json:"ref,omitempty"`
+	Revision string                   `json:"revision,omitempty"`
+	Files    map[string]ManifestEntry `json:"files"`
+}
+
+func NewManifest() *Manifest {
+	return &Manifest{Files: make(map[string]ManifestEntry)}
+}
+
+// LoadManifest reads a manifest, a missing file is an empty manifest
+func LoadManifest(path string) (*Manifest, error) {
+	manifest := NewManifest()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	} else if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return manifest, fmt.Errorf("unable to parse %v: %w", path, err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]ManifestEntry)
+	}
+	return manifest, nil
+}
+
+func (manifest *Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Verify checks a fetched file against its manifest entry
+func (manifest *Manifest) Verify(rdbName string, size int64, sha string) error {
+	expected, ok := manifest.Files[rdbName]
+	if !ok {
+		return nil
+	}
+	if expected.Size != 0 && expected.Size != size {
+		return fmt.Errorf("%w: %v size %v, expected %v", ErrManifestMismatch, rdbName, size, expected.Size)
+	}
+	if expected.SHA256 != "" && !strings.EqualFold(expected.SHA256, sha) {
+		return fmt.Errorf("%w: %v sha256 %v, expected %v", ErrManifestMismatch, rdbName, sha, expected.SHA256)
+	}
+	return nil
+}
+
+// FetchState records where an RDB came from, saved next to it as
+// {rdb}.json so later fetches only download when upstream changed
+type FetchState struct {
+	Source       string `json:"source"`
+	Revision     string `json:"revision,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Size         int64  `json:"size"`
+	SHA256       string `json:"sha256"`
+}
+
+func statePath(rdbPath string) string {
+	return rdbPath + ".json"
+}
+
+// LoadFetchState reads the state of a previous fetch, if any
+func LoadFetchState(rdbPath string) (FetchState, bool) {
+	state := FetchState{}
+	data, err := os.ReadFile(statePath(rdbPath))
+	if err != nil || json.Unmarshal(data, &state) != nil {
+		return state, false
+	}
+	return state, true
+}
+
+func (state FetchState) write(rdbPath string) error {
+	data, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath(rdbPath), append(data, '\n'), 0644)
+}
+
+// Fetcher downloads RDB files from the first mirror that has them.
+// Mirrors are base URLs or local directories holding *.rdb files.
+type Fetcher struct {
+	Mirrors  []string
+	Revision string // libretro-database commit recorded with fetched RDBs
+	Client   *http.Client
+	Retries  int           // attempts after the first on network errors and 5xx
+	Backoff  time.Duration // doubled per retry
+	Manifest *Manifest
+}
+
+// NewFetcher tries mirrors before libretro github at revision, or at
+// master when revision is empty
+func NewFetcher(mirrors []string, revision string) *Fetcher {
+	upstream := RootRdbUrl
+	if revision != "" {
+		upstream = RdbBaseURL(revision)
+	}
+	return &Fetcher{
+		Mirrors:  append(append([]string{}, mirrors...), upstream),
+		Revision: revision,
+		Client:   &http.Client{Timeout: 5 * time.Minute},
+		Retries:  3,
+		Backoff:  2 * time.Second,
+		Manifest: NewManifest(),
+	}
+}
+
+func isRemote(mirror string) bool {
+	return strings.HasPrefix(mirror, "http://") || strings.HasPrefix(mirror, "https://")
+}
+
+// RdbURL joins a mirror base URL and an RDB name
+func RdbURL(mirror string, rdbName string) string {
+	fmtFile := strings.Replace(url.QueryEscape(rdbName), "+", "%20", -1)
+	return strings.TrimSuffix(mirror, "/") + "/" + fmtFile
+}
+
+// Fetch downloads rdbName to rdbPath, verified against the manifest.
+// Returns ErrNotModified when the existing file is already current.
+func (fetcher *Fetcher) Fetch(rdbName string, rdbPath string) (FetchState, error) {
+	errs := []string{}
+	for _, mirror := range fetcher.Mirrors {
+		var state FetchState
+		var err error
+		if isRemote(mirror) {
+			state, err = fetcher.fetchRemote(RdbURL(mirror, rdbName), rdbName, rdbPath)
+		} else {
+			state, err = fetcher.fetchLocal(filepath.Join(mirror, rdbName), rdbName, rdbPath)
+		}
+		if errors.Is(err, ErrNotModified) {
+			if verifyErr := fetcher.Manifest.Verify(rdbName, state.Size, state.SHA256); verifyErr != nil {
+				return state, verifyErr
+			}
+			return state, err
+		} else if err == nil {
+			return state, nil
+		}
+		fmt.Printf("Mirror %v failed: %v\n", mirror, err)
+		errs = append(errs, err.Error())
+	}
+	if state, ok := fetcher.existing(rdbName, rdbPath); ok {
+		return state, fmt.Errorf("%w: %v", ErrKeptExisting, strings.Join(errs, "; "))
+	}
+	return FetchState{}, fmt.Errorf("no mirror could provide %v: %v", rdbName, strings.Join(errs, "; "))
+}
+
+// existing describes the RDB already at rdbPath when it still matches the
+// manifest, for offline and flaky network rebuilds. Its recorded source
+// and revision are only kept when the file is the one they describe.
+func (fetcher *Fetcher) existing(rdbName string, rdbPath string) (FetchState, bool) {
+	stat, err := os.Stat(rdbPath)
+	if err != nil || stat.IsDir() {
+		return FetchState{}, false
+	}
+	sha, err := FileSHA256(rdbPath)
+	if err != nil || fetcher.Manifest.Verify(rdbName, stat.Size(), sha) != nil {
+		return FetchState{}, false
+	}
+	state, ok := LoadFetchState(rdbPath)
+	if !ok || state.SHA256 != sha || state.Size != stat.Size() {
+		state = FetchState{Source: rdbPath, Size: stat.Size(), SHA256: sha}
+		if err := state.write(rdbPath); err != nil {
+			return state, false
+		}
+	}
+	return state, true
+}
+
+type statusError struct {
+	status int
+	url    string
+}
+
+func (err statusError) Error() string {
+	return fmt.Sprintf("GET %v returned HTTP %v", err.url, err.status)
+}
+
+func retryable(err error) bool {
+	status := statusError{}
+	if errors.As(err, &status) {
+		return status.status >= 500 || status.status == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, ErrNotModified) && !errors.Is(err, ErrManifestMismatch)
+}
+
+func (fetcher *Fetcher) fetchRemote(rdbURL string, rdbName string, rdbPath string) (FetchState, error) {
+	backoff := fetcher.Backoff
+	for attempt := 0; ; attempt++ {
+		state, err := fetcher.getRemote(rdbURL, rdbName, rdbPath)
+		if err == nil || attempt >= fetcher.Retries || !retryable(err) {
+			return state, err
+		}
+		fmt.Printf("GET %v failed, retrying in %v: %v\n", rdbURL, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (fetcher *Fetcher) getRemote(rdbURL string, rdbName string, rdbPath string) (FetchState, error) {
+	req, err := http.NewRequest(http.MethodGet, rdbURL, nil)
+	if err != nil {
+		return FetchState{}, err
+	}
+	previous, hasPrevious := LoadFetchState(rdbPath)
+	if _, err := os.Stat(rdbPath); err != nil {
+		hasPrevious = false
+	}
+	if hasPrevious && previous.Source == rdbURL {
+		if previous.ETag != "" {
+			req.Header.Set("If-None-Match", previous.ETag)
+		}
+		if previous.LastModified != "" {
+			req.Header.Set("If-Modified-Since", previous.LastModified)
+		}
+	}
+
+	fmt.Printf("Trying GET %s\n", rdbURL)
+	resp, err := fetcher.Client.Do(req)
+	if err != nil {
+		return FetchState{}, err
+	}
+	defer resp.Body.Close()
+	fmt.Printf("GET StatusCode %v\n", resp.StatusCode)
+	if resp.StatusCode == http.StatusNotModified && hasPrevious {
+		return previous, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return FetchState{}, statusError{status: resp.StatusCode, url: rdbURL}
+	}
+
+	state := FetchState{
+		Source:       rdbURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return fetcher.save(resp.Body, resp.ContentLength, state, rdbName, rdbPath)
+}
+
+func (fetcher *Fetcher) fetchLocal(localPath string, rdbName string, rdbPath string) (FetchState, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return FetchState{}, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return FetchState{}, err
+	}
+
+	// Local mirrors have no ETag, compare content instead
+	if previous, ok := LoadFetchState(rdbPath); ok && previous.Size == stat.Size() {
+		if sha, err := FileSHA256(localPath); err == nil && sha == previous.SHA256 {
+			if existing, err := FileSHA256(rdbPath); err == nil && existing == sha {
+				if revision := fetcher.revisionFor(localPath, rdbName, previous.Size, sha); previous.Revision != revision || previous.Source != localPath {
+					previous.Revision = revision
+					previous.Source = localPath
+					if err := previous.write(rdbPath); err != nil {
+						return previous, err
+					}
+				}
+				return previous, ErrNotModified
+			}
+		}
+	}
+	fmt.Printf("Copying %s\n", localPath)
+	return fetcher.save(file, stat.Size(), FetchState{Source: localPath}, rdbName, rdbPath)
+}
+
+// save streams to a temp file next to rdbPath, verifies it and renames
+// it into place, so failed fetches never replace a good RDB
+func (fetcher *Fetcher) save(body io.Reader, expectedSize int64, state FetchState, rdbName string, rdbPath string) (FetchState, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(rdbPath), ".rdb-*")
+	if err != nil {
+		return state, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return state, err
+	}
+	if expectedSize >= 0 && size != expectedSize {
+		return state, fmt.Errorf("%v truncated, got %v of %v bytes", rdbName, size, expectedSize)
+	}
+	state.Size = size
+	state.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if err := fetcher.Manifest.Verify(rdbName, state.Size, state.SHA256); err != nil {
+		return state, err
+	}
+	state.Revision = fetcher.revisionFor(state.Source, rdbName, state.Size, state.SHA256)
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return state, err
+	}
+	if err := os.Rename(tmp.Name(), rdbPath); err != nil {
+		return state, err
+	}
+	return state, state.write(rdbPath)
+}
+
+// revisionFor is the pinned revision when a file provably comes from it:
+// fetched from libretro github at that commit, or matching the manifest
+// entry locked to it. Other mirrors may serve any revision, so their
+// files are recorded without one.
+func (fetcher *Fetcher) revisionFor(source string, rdbName string, size int64, sha string) string {
+	if fetcher.Revision == "" {
+		return ""
+	}
+	if source == RdbURL(RdbBaseURL(fetcher.Revision), rdbName) {
+		return fetcher.Revision
+	}
+	if _, ok := fetcher.Manifest.Files[rdbName]; ok && fetcher.Manifest.Revision == fetcher.Revision &&
+		fetcher.Manifest.Verify(rdbName, size, sha) == nil {
+		return fetcher.Revision
+	}
+	return ""
+}
+
+// FileSHA256 hashes a file as lowercase hex
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -8,7 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/utils"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/romname"
 )
 
 type RdbJsonROM struct {
@@ -74,16 +74,15 @@ func ParseNDJSON(jsonStream []byte) ([]RdbJsonROM, error) {
 }
 
 // Reindex dedupe on slug
-// replace map if full romname is shorter (favors USA)
-// Target only one per permutation
-func MapDupeROMSlugs(roms []RdbJsonROM) map[string]string {
+// replace map if romname ranks higher by region/language preference
+// Target only one per permutation, multi-disk sets share a slug and
+// are represented by their first disk
+func MapDupeROMSlugs(roms []RdbJsonROM, pref romname.Preference, convention romname.Convention) map[string]string {
 	dupeMap := make(map[string]string) // slug:romName
 	for _, rom := range roms {
-		fileExt := filepath.Ext(rom.RomName)
-		filename, _ := utils.CutSuffix(rom.RomName, fileExt)
-		slug := utils.SlugifyString(filename)
+		slug := romname.Slugify(rom.RomName, convention)
 		if existing, ok := dupeMap[slug]; ok {
-			if len(rom.RomName) < len(existing) {
+			if pref.Less(rom.RomName, existing, convention) {
 				dupeMap[slug] = rom.RomName
 			}
 		} else {
@@ -0,0 +1,105 @@
+package rdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const LibretroDatabaseRepo = "libretro/libretro-database"
+
+var GitHubAPIUrl string = "https://api.github.com"
+
+var reCommitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// RdbBaseURL is the rdb folder of libretro-database at a commit, tag or branch
+func RdbBaseURL(revision string) string {
+	return fmt.Sprintf("https://github.com/%v/raw/%v/rdb/", LibretroDatabaseRepo, url.PathEscape(revision))
+}
+
+func githubGet(client *http.Client, apiPath string, accept string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(GitHubAPIUrl, "/")+apiPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %v returned HTTP %v: %v", apiPath, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// ResolveRevision resolves a libretro-database commit, tag or branch to a
+// full commit SHA. Full SHAs are returned as is, without network access.
+func ResolveRevision(client *http.Client, ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if reCommitSHA.MatchString(strings.ToLower(ref)) {
+		return strings.ToLower(ref), nil
+	}
+	body, err := githubGet(client, fmt.Sprintf("/repos/%v/commits/%v", LibretroDatabaseRepo, url.PathEscape(ref)), "application/vnd.github.sha")
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve libretro-database ref %v: %w", ref, err)
+	}
+	sha := strings.TrimSpace(string(body))
+	if !reCommitSHA.MatchString(sha) {
+		return "", fmt.Errorf("unexpected commit SHA %q for ref %v", sha, ref)
+	}
+	return sha, nil
+}
+
+type compareResponse struct {
+	Status   string `json:"status"`
+	AheadBy  int    `json:"ahead_by"`
+	BehindBy int    `json:"behind_by"`
+	Files    []struct {
+		Filename         string `json:"filename"`
+		PreviousFilename string `json:"previous_filename"`
+		Status           string `json:"status"`
+	} `json:"files"`
+}
+
+// Comparison lists RDB files changed between two libretro-database revisions
+type Comparison struct {
+	Base      string
+	Head      string
+	AheadBy   int
+	Changed   map[string]string // [rdb name]added, modified, removed or renamed
+	Truncated bool              // GitHub lists at most 300 files per compare
+}
+
+// CompareRevisions asks GitHub which rdb/*.rdb files changed from base to head
+func CompareRevisions(client *http.Client, base string, head string) (Comparison, error) {
+	comparison := Comparison{Base: base, Head: head, Changed: make(map[string]string)}
+	body, err := githubGet(client, fmt.Sprintf("/repos/%v/compare/%v...%v", LibretroDatabaseRepo, url.PathEscape(base), url.PathEscape(head)), "application/vnd.github+json")
+	if err != nil {
+		return comparison, err
+	}
+	response := compareResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return comparison, err
+	}
+	comparison.AheadBy = response.AheadBy
+	comparison.Truncated = len(response.Files) >= 300
+	for _, file := range response.Files {
+		for _, filename := range []string{file.Filename, file.PreviousFilename} {
+			if path.Dir(filename) == "rdb" && path.Ext(filename) == ".rdb" {
+				comparison.Changed[path.Base(filename)] = file.Status
+			}
+		}
+	}
+	return comparison, nil
+}
@@ -0,0 +1,32 @@
+package romname
+
+import (
+	"path/filepath"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/utils"
+)
+
+// Convention is the naming scheme of a ROM set, set per DataConfig
+type Convention string
+
+const (
+	ConventionNoIntro Convention = ""
+	ConventionTOSEC   Convention = "tosec"
+)
+
+// Slugify maps a ROM filename to the slug shared by all its variants
+func Slugify(name string, convention Convention) string {
+	if convention == ConventionTOSEC {
+		return ParseTOSEC(name).Slug()
+	}
+	filename, _ := utils.CutSuffix(name, filepath.Ext(name))
+	return utils.SlugifyString(filename)
+}
+
+// Parse reads filename tags by convention
+func Parse(name string, convention Convention) Tags {
+	if convention == ConventionTOSEC {
+		return ParseTOSEC(name).Tags()
+	}
+	return ParseNoIntro(name)
+}
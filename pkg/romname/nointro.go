@@ -0,0 +1,297 @@
+package romname
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+)
+
+// Tags are the No-Intro attributes parsed from () and [] groups of a ROM name
+// e.g. "Super Mario World (Europe) (En,Fr,De) (Rev 1) [b]"
+type Tags struct {
+	Title       string
+	Regions     []string
+	Languages   []string
+	Revision    string
+	Version     string
+	Beta        bool
+	Proto       bool
+	Demo        bool
+	Unlicensed  bool
+	Pirate      bool
+	Hack        bool
+	Translation string // target language of a fan translation, "Unknown" if untagged
+	Verified    bool
+	BadDump     bool
+	Alternate   bool
+	Disk        int    // disk, disc or tape number of a multi-disk set
+	Side        string // disk or tape side letter
+	Other       []string
+}
+
+var Regions = []string{
+	"World", "USA", "Europe", "Japan", "Asia", "Australia", "Brazil", "Canada",
+	"China", "Denmark", "Finland", "France", "Germany", "Greece", "Hong Kong",
+	"India", "Ireland", "Israel", "Italy", "Korea", "Latin America", "Mexico",
+	"Netherlands", "New Zealand", "Norway", "Poland", "Portugal", "Russia",
+	"Scandinavia", "South Africa", "Spain", "Sweden", "Switzerland", "Taiwan",
+	"UK", "Unknown",
+}
+
+// Implied language when a No-Intro name has no language group
+var regionLanguages = map[string]string{
+	"World": "En", "USA": "En", "Europe": "En", "Australia": "En", "Canada": "En",
+	"UK": "En", "Ireland": "En", "New Zealand": "En", "South Africa": "En",
+	"Japan": "Ja", "Korea": "Ko", "China": "Zh", "Taiwan": "Zh", "Hong Kong": "Zh",
+	"Brazil": "Pt", "Portugal": "Pt", "France": "Fr", "Germany": "De", "Italy": "It",
+	"Spain": "Es", "Mexico": "Es", "Latin America": "Es", "Netherlands": "Nl",
+	"Sweden": "Sv", "Norway": "No", "Denmark": "Da", "Finland": "Fi", "Russia": "Ru",
+	"Poland": "Pl", "Greece": "El",
+}
+
+var regionSet = func() map[string]string {
+	set := make(map[string]string, len(Regions))
+	for _, region := range Regions {
+		set[strings.ToLower(region)] = region
+	}
+	return set
+}()
+
+var (
+	reGroup    = regexp.MustCompile(`\(([^()]*)\)|\[([^\[\]]*)\]`)
+	reLanguage = regexp.MustCompile(`^[A-Z][a-z](-[A-Z][a-z]+)?$`)
+	reRevision = regexp.MustCompile(`^Rev\s*([0-9A-Za-z.]+)$`)
+	reVersion  = regexp.MustCompile(`^[vV]([0-9][0-9A-Za-z.]*)$`)
+	reBadDump  = regexp.MustCompile(`^b[0-9]*$`)
+	reAltDump  = regexp.MustCompile(`^a[0-9]*$`)
+	reHackDump = regexp.MustCompile(`^h[0-9]*$`)
+	reTransTag = regexp.MustCompile(`^T[+-]([A-Za-z]+)`)
+	reBeta     = regexp.MustCompile(`^Beta(\s*[0-9]*)?$`)
+	reProto    = regexp.MustCompile(`^Proto(type)?(\s*[0-9]*)?$`)
+	reDemo     = regexp.MustCompile(`^(Demo|Sample|Kiosk)(\s*[0-9]*)?$`)
+	reDisk     = regexp.MustCompile(`^(?:Disk|Disc|Tape)\s+([0-9]+)(?:\s+of\s+[0-9]+)?(?:\s+Side\s+([A-Z]))?$`)
+	reSide     = regexp.MustCompile(`^Side\s+([A-Z])$`)
+)
+
+// Title strips all tag groups and the file extension
+func Title(name string) string {
+	name = strings.TrimSuffix(name, extension(name))
+	if idx := strings.IndexAny(name, "(["); idx >= 0 {
+		name = name[:idx]
+	}
+	return strings.TrimSpace(name)
+}
+
+// Known ROM extensions are short, dotted words in titles like "Dr. Mario" are not
+func extension(name string) string {
+	idx := strings.LastIndex(name, ".")
+	if idx < 0 || len(name)-idx > 5 || strings.ContainsAny(name[idx:], " )]") {
+		return ""
+	}
+	return name[idx:]
+}
+
+func ParseNoIntro(name string) Tags {
+	tags := Tags{Title: Title(name)}
+	for _, match := range reGroup.FindAllStringSubmatch(name, -1) {
+		if strings.HasPrefix(match[0], "[") {
+			parseFlag(&tags, strings.TrimSpace(match[2]))
+			continue
+		}
+		parseGroup(&tags, match[1])
+	}
+	return tags
+}
+
+// [] dump flags, GoodTools style as found in hacks and translations
+func parseFlag(tags *Tags, flag string) {
+	switch {
+	case flag == "!":
+		tags.Verified = true
+	case reBadDump.MatchString(flag):
+		tags.BadDump = true
+	case reAltDump.MatchString(flag):
+		tags.Alternate = true
+	case reHackDump.MatchString(flag):
+		tags.Hack = true
+	case reTransTag.MatchString(flag):
+		tags.Translation = reTransTag.FindStringSubmatch(flag)[1]
+	case flag != "":
+		tags.Other = append(tags.Other, "["+flag+"]")
+	}
+}
+
+func parseGroup(tags *Tags, group string) {
+	group = strings.TrimSpace(group)
+	if rev := reRevision.FindStringSubmatch(group); rev != nil {
+		tags.Revision = rev[1]
+		return
+	}
+	if ver := reVersion.FindStringSubmatch(group); ver != nil {
+		tags.Version = ver[1]
+		return
+	}
+	if disk := reDisk.FindStringSubmatch(group); disk != nil {
+		tags.Disk, _ = strconv.Atoi(disk[1])
+		tags.Side = disk[2]
+		return
+	}
+	if side := reSide.FindStringSubmatch(group); side != nil {
+		tags.Side = side[1]
+		return
+	}
+
+	switch {
+	case reBeta.MatchString(group):
+		tags.Beta = true
+		return
+	case reProto.MatchString(group):
+		tags.Proto = true
+		return
+	case reDemo.MatchString(group):
+		tags.Demo = true
+		return
+	case group == "Unl":
+		tags.Unlicensed = true
+		return
+	case group == "Pirate":
+		tags.Pirate = true
+		return
+	case group == "Hack" || strings.HasPrefix(group, "Hack "):
+		tags.Hack = true
+		return
+	case strings.HasPrefix(group, "Translated"):
+		tags.Translation = strings.TrimSpace(strings.TrimPrefix(group, "Translated"))
+		if tags.Translation == "" {
+			tags.Translation = "Unknown"
+		}
+		return
+	}
+
+	parts := strings.Split(group, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	if regions := matchAll(parts, func(part string) (string, bool) {
+		region, ok := regionSet[strings.ToLower(part)]
+		return region, ok
+	}); regions != nil && len(tags.Regions) == 0 {
+		tags.Regions = regions
+		return
+	}
+
+	if languages := matchAll(parts, func(part string) (string, bool) {
+		return part, reLanguage.MatchString(part)
+	}); languages != nil && len(tags.Languages) == 0 {
+		tags.Languages = languages
+		return
+	}
+
+	tags.Other = append(tags.Other, "("+group+")")
+}
+
+// Prerelease, hacked or broken dumps are never the preferred ROM for a game
+func (tags Tags) IsClean() bool {
+	return !(tags.Beta || tags.Proto || tags.Demo || tags.Pirate || tags.Hack || tags.BadDump || tags.Translation != "")
+}
+
+// A group counts only if every comma separated part matches
+func matchAll(parts []string, match func(string) (string, bool)) []string {
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		value, ok := match(part)
+		if !ok {
+			return nil
+		}
+		out = append(out, value)
+	}
+	return out
+}
+
+// EffectiveLanguages falls back to languages implied by region
+func (tags Tags) EffectiveLanguages() []string {
+	if len(tags.Languages) > 0 {
+		return tags.Languages
+	}
+	languages := []string{}
+	for _, region := range tags.Regions {
+		if language, ok := regionLanguages[region]; ok {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
+
+// RevisionNumber orders revisions, "Rev 1" and "Rev A" both rank 1, none is 0
+func (tags Tags) RevisionNumber() float64 {
+	return orderNumber(tags.Revision)
+}
+
+// DiskOrder sorts the sets first disk and side first, single disk games are 0
+func (tags Tags) DiskOrder() int {
+	order := tags.Disk * 26
+	if tags.Side != "" {
+		order += int(tags.Side[0]-'A') + 1
+	}
+	return order
+}
+
+// VersionNumber orders versions, "v1.1" ranks 1.1, none is 0
+func (tags Tags) VersionNumber() float64 {
+	return orderNumber(tags.Version)
+}
+
+func orderNumber(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	if num, err := strconv.ParseFloat(value, 64); err == nil {
+		return num
+	}
+	// v1.0.2 style, major.minor is enough to order
+	if parts := strings.SplitN(value, ".", 3); len(parts) == 3 {
+		if num, err := strconv.ParseFloat(parts[0]+"."+parts[1], 64); err == nil {
+			return num
+		}
+	}
+	letter := strings.ToUpper(value)[0]
+	if letter >= 'A' && letter <= 'Z' {
+		return float64(letter-'A') + 1
+	}
+	return 0
+}
+
+// RomTag flattens tags for the MGDB RomTag table
+func (tags Tags) RomTag(romName string, crc string) mgdb.RomTag {
+	return mgdb.RomTag{
+		RomName:      romName,
+		CRC32:        crc,
+		Title:        tags.Title,
+		Regions:      strings.Join(tags.Regions, ","),
+		Languages:    strings.Join(tags.EffectiveLanguages(), ","),
+		Revision:     tags.Revision,
+		Version:      tags.Version,
+		IsBeta:       boolInt(tags.Beta),
+		IsProto:      boolInt(tags.Proto),
+		IsDemo:       boolInt(tags.Demo),
+		IsUnlicensed: boolInt(tags.Unlicensed),
+		IsPirate:     boolInt(tags.Pirate),
+		IsHack:       boolInt(tags.Hack),
+		Translation:  tags.Translation,
+		IsVerified:   boolInt(tags.Verified),
+		IsBadDump:    boolInt(tags.BadDump),
+		IsAlternate:  boolInt(tags.Alternate),
+		Extra:        strings.Join(tags.Other, " "),
+	}
+}
+
+// SQLite has no bool, matches Game.IsIndexed
+func boolInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
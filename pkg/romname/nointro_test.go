@@ -0,0 +1,84 @@
+package romname
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNoIntro(t *testing.T) {
+	tests := []struct {
+		name string
+		want Tags
+	}{
+		{
+			name: "Super Mario World (USA).sfc",
+			want: Tags{Title: "Super Mario World", Regions: []string{"USA"}},
+		},
+		{
+			name: "Super Mario World (Europe) (En,Fr,De) (Rev 1) [b]",
+			want: Tags{
+				Title: "Super Mario World", Regions: []string{"Europe"}, Languages: []string{"En", "Fr", "De"},
+				Revision: "1", BadDump: true,
+			},
+		},
+		{
+			name: "Dr. Mario (Japan, USA) (v1.1).nes",
+			want: Tags{Title: "Dr. Mario", Regions: []string{"Japan", "USA"}, Version: "1.1"},
+		},
+		{
+			name: "Tetris (World) (Beta) (Unl) [!]",
+			want: Tags{Title: "Tetris", Regions: []string{"World"}, Beta: true, Unlicensed: true, Verified: true},
+		},
+		{
+			name: "Sonic (USA) (Proto 2) (Pirate)",
+			want: Tags{Title: "Sonic", Regions: []string{"USA"}, Proto: true, Pirate: true},
+		},
+		{
+			name: "Zelda (Japan) (Translated En) [a2]",
+			want: Tags{Title: "Zelda", Regions: []string{"Japan"}, Translation: "En", Alternate: true},
+		},
+		{
+			name: "Zelda (Japan) [T+Eng1.0]",
+			want: Tags{Title: "Zelda", Regions: []string{"Japan"}, Translation: "Eng"},
+		},
+		{
+			name: "Metroid (USA) (Hack) [h1]",
+			want: Tags{Title: "Metroid", Regions: []string{"USA"}, Hack: true},
+		},
+		{
+			// only a bare h with an optional number is the GoodTools hack flag
+			name: "Metroid (USA) [hI]",
+			want: Tags{Title: "Metroid", Regions: []string{"USA"}, Other: []string{"[hI]"}},
+		},
+		{
+			name: "Final Fantasy (USA) (Disc 2 of 3 Side B) (Kiosk)",
+			want: Tags{Title: "Final Fantasy", Regions: []string{"USA"}, Disk: 2, Side: "B", Demo: true},
+		},
+		{
+			name: "Game (USA) (Collector's Edition)",
+			want: Tags{Title: "Game", Regions: []string{"USA"}, Other: []string{"(Collector's Edition)"}},
+		},
+	}
+	for _, test := range tests {
+		if got := ParseNoIntro(test.name); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseNoIntro(%q)\n got %+v\nwant %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEffectiveLanguages(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"Game (Japan)", []string{"Ja"}},
+		{"Game (USA, Europe)", []string{"En", "En"}},
+		{"Game (Europe) (Fr,De)", []string{"Fr", "De"}},
+		{"Game (Unknown)", []string{}},
+	}
+	for _, test := range tests {
+		if got := ParseNoIntro(test.name).EffectiveLanguages(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("EffectiveLanguages of %q = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
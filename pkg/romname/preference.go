@@ -0,0 +1,82 @@
+package romname
+
+import (
+	"strings"
+)
+
+// Preference decides which ROM represents a slug when touching and scraping.
+// Earlier entries win; unlisted regions and languages rank after listed ones.
+type Preference struct {
+	Regions   []string
+	Languages []string
+}
+
+var DefaultPreference = Preference{
+	Regions:   []string{"USA", "World", "Europe", "Japan"},
+	Languages: []string{"En"},
+}
+
+// ParsePreference reads comma separated lists such as "Japan,USA" and "Ja,En".
+// Empty lists keep the default.
+func ParsePreference(regions string, languages string) Preference {
+	pref := DefaultPreference
+	if list := splitList(regions); len(list) > 0 {
+		pref.Regions = list
+	}
+	if list := splitList(languages); len(list) > 0 {
+		pref.Languages = list
+	}
+	return pref
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+func rank(list []string, values []string) int {
+	best := len(list)
+	for _, value := range values {
+		for i, item := range list {
+			if i < best && strings.EqualFold(item, value) {
+				best = i
+			}
+		}
+	}
+	return best
+}
+
+// Less reports whether ROM name a is preferred over b.
+// Order: clean release, region, language, first disk, latest revision and
+// version, then shortest name.
+func (pref Preference) Less(a string, b string, convention Convention) bool {
+	tagsA, tagsB := Parse(a, convention), Parse(b, convention)
+
+	if cleanA, cleanB := tagsA.IsClean(), tagsB.IsClean(); cleanA != cleanB {
+		return cleanA
+	}
+	if rankA, rankB := rank(pref.Regions, tagsA.Regions), rank(pref.Regions, tagsB.Regions); rankA != rankB {
+		return rankA < rankB
+	}
+	if rankA, rankB := rank(pref.Languages, tagsA.EffectiveLanguages()), rank(pref.Languages, tagsB.EffectiveLanguages()); rankA != rankB {
+		return rankA < rankB
+	}
+	if diskA, diskB := tagsA.DiskOrder(), tagsB.DiskOrder(); diskA != diskB {
+		return diskA < diskB
+	}
+	if revA, revB := tagsA.RevisionNumber(), tagsB.RevisionNumber(); revA != revB {
+		return revA > revB
+	}
+	if verA, verB := tagsA.VersionNumber(), tagsB.VersionNumber(); verA != verB {
+		return verA > verB
+	}
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
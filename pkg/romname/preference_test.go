@@ -0,0 +1,51 @@
+package romname
+
+import (
+	"testing"
+)
+
+func TestPreferenceLess(t *testing.T) {
+	japanFirst := ParsePreference("Japan,USA", "Ja")
+	tests := []struct {
+		pref   Preference
+		better string
+		worse  string
+	}{
+		// clean releases beat any region
+		{DefaultPreference, "Game (Japan)", "Game (USA) (Beta)"},
+		{DefaultPreference, "Game (Japan)", "Game (USA) [b]"},
+		{DefaultPreference, "Game (Japan)", "Game (USA) [h1]"},
+		{DefaultPreference, "Game (Japan)", "Game (USA) (Translated Fr)"},
+		// an alternate dump is still a clean release
+		{DefaultPreference, "Game (USA) [a]", "Game (Europe)"},
+		{DefaultPreference, "Game (USA)", "Game (Europe)"},
+		{DefaultPreference, "Game (Europe)", "Game (Brazil)"},
+		{japanFirst, "Game (Japan)", "Game (USA)"},
+		// same region, listed language first
+		{DefaultPreference, "Game (Europe) (En,Fr)", "Game (Europe) (Fr,De)"},
+		{DefaultPreference, "Game (USA) (Disc 1)", "Game (USA) (Disc 2)"},
+		{DefaultPreference, "Game (USA) (Rev 2)", "Game (USA) (Rev 1)"},
+		{DefaultPreference, "Game (USA) (Rev 1)", "Game (USA)"},
+		{DefaultPreference, "Game (USA) (v1.1)", "Game (USA) (v1.0)"},
+		{DefaultPreference, "Game (USA).sfc", "Game (USA) (Sample).sfc"},
+		{DefaultPreference, "Game (USA) (Alt)", "Game (USA) (Alt 2)"},
+	}
+	for _, test := range tests {
+		if !test.pref.Less(test.better, test.worse, ConventionNoIntro) {
+			t.Errorf("%q should be preferred over %q", test.better, test.worse)
+		}
+		if test.pref.Less(test.worse, test.better, ConventionNoIntro) {
+			t.Errorf("%q should not be preferred over %q", test.worse, test.better)
+		}
+	}
+}
+
+func TestParsePreference(t *testing.T) {
+	pref := ParsePreference(" Japan , ,USA", "")
+	if len(pref.Regions) != 2 || pref.Regions[0] != "Japan" || pref.Regions[1] != "USA" {
+		t.Errorf("Regions %v", pref.Regions)
+	}
+	if len(pref.Languages) != 1 || pref.Languages[0] != "En" {
+		t.Errorf("Languages %v, want the default", pref.Languages)
+	}
+}
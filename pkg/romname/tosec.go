@@ -0,0 +1,202 @@
+package romname
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/utils"
+)
+
+// TOSEC is a ROM name in TOSEC convention, common for computer sets
+// e.g. "Lemmings v1.1 (1991)(Psygnosis)(GB)(en)(Disk 1 of 2)[cr CSL][!].adf"
+type TOSEC struct {
+	Title     string
+	Version   string
+	Demo      bool
+	Year      string
+	Publisher string
+	Countries []string
+	Languages []string
+	DevStatus string
+	Disk      int
+	DiskTotal int
+	Side      string
+	Flags     []string // raw [] dump flags, e.g. "cr CSL", "t +2", "a"
+	Other     []string
+}
+
+// TOSEC country codes to No-Intro region names
+var tosecCountries = map[string]string{
+	"AE": "Asia", "AS": "Asia", "AT": "Europe", "AU": "Australia", "BE": "Europe",
+	"BR": "Brazil", "CA": "Canada", "CH": "Switzerland", "CN": "China", "CZ": "Europe",
+	"DE": "Germany", "DK": "Denmark", "ES": "Spain", "EU": "Europe", "FI": "Finland",
+	"FR": "France", "GB": "UK", "GR": "Greece", "HK": "Hong Kong", "IE": "Ireland",
+	"IL": "Israel", "IN": "India", "IT": "Italy", "JP": "Japan", "KR": "Korea",
+	"MX": "Mexico", "NL": "Netherlands", "NO": "Norway", "NZ": "New Zealand",
+	"PL": "Poland", "PT": "Portugal", "RU": "Russia", "SE": "Sweden", "TW": "Taiwan",
+	"US": "USA", "ZA": "South Africa",
+}
+
+var (
+	reTosecVersion = regexp.MustCompile(`\s+(v[0-9][0-9A-Za-z.]*|Rev [0-9A-Za-z.]+)$`)
+	reTosecDemo    = regexp.MustCompile(`^demo(-[a-z]+)?$`)
+	reTosecYear    = regexp.MustCompile(`^(19|20)[0-9x]{2}(-[0-9x]{2}(-[0-9x]{2})?)?$`)
+	reTosecCountry = regexp.MustCompile(`^[A-Z]{2}(-[A-Z]{2})*$`)
+	reTosecLang    = regexp.MustCompile(`^[a-z]{2}(-[a-z]{2})*$`)
+	reTosecStatus  = regexp.MustCompile(`^(alpha|beta|preview|pre-release|proto)[0-9]*$`)
+	reTosecMedia   = regexp.MustCompile(`^(?:(?:Disk|Disc|Tape|Part|File)\s+([0-9]+)(?:\s+of\s+([0-9]+))?)?\s*(?:Side\s+([A-Z]))?$`)
+)
+
+// ParseTOSEC reads the positional TOSEC fields, title and year/publisher
+// first, then optional country, language, status and media groups
+func ParseTOSEC(name string) TOSEC {
+	tosec := TOSEC{}
+	name = strings.TrimSuffix(name, extension(name))
+
+	title := name
+	if idx := strings.IndexAny(name, "(["); idx >= 0 {
+		title = name[:idx]
+	}
+	title = strings.TrimSpace(title)
+	if ver := reTosecVersion.FindStringSubmatch(title); ver != nil {
+		tosec.Version = strings.TrimPrefix(strings.TrimPrefix(ver[1], "v"), "Rev ")
+		title = strings.TrimSpace(title[:len(title)-len(ver[0])])
+	}
+	tosec.Title = title
+
+	position := 0
+	for _, match := range reGroup.FindAllStringSubmatch(name, -1) {
+		if strings.HasPrefix(match[0], "[") {
+			if flag := strings.TrimSpace(match[2]); flag != "" {
+				tosec.Flags = append(tosec.Flags, flag)
+			}
+			continue
+		}
+		group := strings.TrimSpace(match[1])
+
+		switch {
+		case position == 0 && reTosecDemo.MatchString(group):
+			tosec.Demo = true
+			continue
+		case position == 0 && reTosecYear.MatchString(group):
+			tosec.Year = group
+			position = 1
+			continue
+		case position == 1:
+			if group != "-" {
+				tosec.Publisher = group
+			}
+			position = 2
+			continue
+		}
+
+		if media := reTosecMedia.FindStringSubmatch(group); media != nil && group != "" {
+			tosec.Disk, _ = strconv.Atoi(media[1])
+			tosec.DiskTotal, _ = strconv.Atoi(media[2])
+			tosec.Side = media[3]
+			continue
+		}
+		switch {
+		case reTosecCountry.MatchString(group):
+			tosec.Countries = append(tosec.Countries, strings.Split(group, "-")...)
+		case reTosecLang.MatchString(group):
+			tosec.Languages = append(tosec.Languages, strings.Split(group, "-")...)
+		case reTosecStatus.MatchString(group):
+			tosec.DevStatus = group
+		default:
+			tosec.Other = append(tosec.Other, "("+group+")")
+		}
+	}
+	return tosec
+}
+
+// Slug keeps year and publisher, the best disambiguators on computer sets.
+// Media groups are dropped so all disks and sides of a release share a slug.
+func (tosec TOSEC) Slug() string {
+	return utils.SlugifyString(tosec.Title) +
+		utils.SlugifyString(tosec.Year) +
+		utils.SlugifyString(tosec.Publisher)
+}
+
+func (tosec TOSEC) hasFlag(prefixes ...string) bool {
+	for _, flag := range tosec.Flags {
+		if flagIs(flag, prefixes...) {
+			return true
+		}
+	}
+	return false
+}
+
+// flagIs matches a flag's code, "a" matches "a", "a2" and "a2 Lenslok"
+func flagIs(flag string, prefixes ...string) bool {
+	code := strings.Fields(flag)[0]
+	for _, prefix := range prefixes {
+		if code == prefix || (strings.HasPrefix(code, prefix) && isDigits(code[len(prefix):])) {
+			return true
+		}
+	}
+	return false
+}
+
+// tosecTagged are the flag codes Tags maps to a field of their own
+var tosecTagged = []string{"p", "h", "!", "b", "o", "u", "v", "a", "tr"}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Tags maps TOSEC fields onto the shared No-Intro tag set
+func (tosec TOSEC) Tags() Tags {
+	tags := Tags{
+		Title:     tosec.Title,
+		Version:   tosec.Version,
+		Demo:      tosec.Demo,
+		Beta:      strings.HasPrefix(tosec.DevStatus, "alpha") || strings.HasPrefix(tosec.DevStatus, "beta") || strings.HasPrefix(tosec.DevStatus, "pre"),
+		Proto:     strings.HasPrefix(tosec.DevStatus, "proto"),
+		Pirate:    tosec.hasFlag("p"),
+		Hack:      tosec.hasFlag("h"),
+		Verified:  tosec.hasFlag("!"),
+		BadDump:   tosec.hasFlag("b", "o", "u", "v"),
+		Alternate: tosec.hasFlag("a"),
+		Disk:      tosec.Disk,
+		Side:      tosec.Side,
+		Other:     tosec.Other,
+	}
+	if tosec.hasFlag("tr") {
+		tags.Translation = "Unknown"
+		for _, flag := range tosec.Flags {
+			if fields := strings.Fields(flag); len(fields) > 1 && fields[0] == "tr" {
+				tags.Translation = fields[1]
+			}
+		}
+	}
+	// Cracked, fixed, trained and modified dumps are kept as released
+	// software, their flags stay visible in Extra
+	for _, flag := range tosec.Flags {
+		if !flagIs(flag, tosecTagged...) {
+			tags.Other = append(tags.Other, "["+flag+"]")
+		}
+	}
+	for _, country := range tosec.Countries {
+		if region, ok := tosecCountries[country]; ok {
+			tags.Regions = append(tags.Regions, region)
+		}
+	}
+	for _, language := range tosec.Languages {
+		tags.Languages = append(tags.Languages, strings.ToUpper(language[:1])+language[1:])
+	}
+	// Year and publisher have no column of their own in RomTag
+	if tosec.Publisher != "" {
+		tags.Other = append([]string{"(" + tosec.Publisher + ")"}, tags.Other...)
+	}
+	if tosec.Year != "" {
+		tags.Other = append([]string{"(" + tosec.Year + ")"}, tags.Other...)
+	}
+	return tags
+}
@@ -0,0 +1,103 @@
+package romname
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTOSEC(t *testing.T) {
+	tests := []struct {
+		name string
+		want TOSEC
+	}{
+		{
+			name: "Lemmings v1.1 (1991)(Psygnosis)(GB)(en)(Disk 1 of 2)[cr CSL][!].adf",
+			want: TOSEC{
+				Title: "Lemmings", Version: "1.1", Year: "1991", Publisher: "Psygnosis",
+				Countries: []string{"GB"}, Languages: []string{"en"}, Disk: 1, DiskTotal: 2,
+				Flags: []string{"cr CSL", "!"},
+			},
+		},
+		{
+			name: "Elite (demo) (1984)(-)(Side B)[a2].tap",
+			want: TOSEC{Title: "Elite", Demo: true, Year: "1984", Side: "B", Flags: []string{"a2"}},
+		},
+		{
+			name: "Turrican (19xx)(Rainbow Arts)(DE-AT)(de-en)(beta)(Tape 2 Side A)[t +2]",
+			want: TOSEC{
+				Title: "Turrican", Year: "19xx", Publisher: "Rainbow Arts", Countries: []string{"DE", "AT"},
+				Languages: []string{"de", "en"}, DevStatus: "beta", Disk: 2, Side: "A", Flags: []string{"t +2"},
+			},
+		},
+		{
+			name: "Game Rev 2 (1990-05)(Ocean)(US)(Unreleased)",
+			want: TOSEC{
+				Title: "Game", Version: "2", Year: "1990-05", Publisher: "Ocean", Countries: []string{"US"},
+				Other: []string{"(Unreleased)"},
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := ParseTOSEC(test.name); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseTOSEC(%q)\n got %+v\nwant %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTOSECSlug(t *testing.T) {
+	disk1 := ParseTOSEC("Lemmings (1991)(Psygnosis)(Disk 1 of 2).adf").Slug()
+	disk2 := ParseTOSEC("Lemmings (1991)(Psygnosis)(Disk 2 of 2)[cr CSL].adf").Slug()
+	other := ParseTOSEC("Lemmings (1993)(Psygnosis).adf").Slug()
+	if disk1 != disk2 {
+		t.Errorf("disks of one release slug apart, %v and %v", disk1, disk2)
+	}
+	if disk1 == other {
+		t.Errorf("releases of different years share slug %v", disk1)
+	}
+}
+
+func TestTOSECTags(t *testing.T) {
+	tests := []struct {
+		name string
+		want Tags
+	}{
+		{
+			name: "Lemmings (1991)(Psygnosis)(GB)(en)[!]",
+			want: Tags{
+				Title: "Lemmings", Regions: []string{"UK"}, Languages: []string{"En"}, Verified: true,
+				Other: []string{"(1991)", "(Psygnosis)"},
+			},
+		},
+		{
+			name: "Lemmings (1991)(Psygnosis)[h Team]",
+			want: Tags{Title: "Lemmings", Hack: true, Other: []string{"(1991)", "(Psygnosis)"}},
+		},
+		{
+			// cracked, fixed, trained and modified dumps are not hacks
+			name: "Lemmings (1991)(Psygnosis)[cr CSL][f NTSC][t +2][m]",
+			want: Tags{
+				Title: "Lemmings",
+				Other: []string{"(1991)", "(Psygnosis)", "[cr CSL]", "[f NTSC]", "[t +2]", "[m]"},
+			},
+		},
+		{
+			name: "Lemmings (1991)(Psygnosis)(proto)[b2][a][p Pirate][tr de]",
+			want: Tags{
+				Title: "Lemmings", Proto: true, BadDump: true, Alternate: true, Pirate: true, Translation: "de",
+				Other: []string{"(1991)", "(Psygnosis)"},
+			},
+		},
+		{
+			name: "Lemmings (1991)(Psygnosis)(Disk 2 of 2 Side B)",
+			want: Tags{Title: "Lemmings", Disk: 2, Side: "B", Other: []string{"(1991)", "(Psygnosis)"}},
+		},
+	}
+	for _, test := range tests {
+		if got := ParseTOSEC(test.name).Tags(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Tags of %q\n got %+v\nwant %+v", test.name, got, test.want)
+		}
+	}
+	if !ParseTOSEC("Lemmings (1991)(Psygnosis)[cr CSL]").Tags().IsClean() {
+		t.Error("a cracked dump should count as a clean release")
+	}
+}
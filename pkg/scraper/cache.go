@@ -0,0 +1,239 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrOffline is returned for cache misses when the cache may not go online
+var ErrOffline = errors.New("not in offline cache")
+
+// Cache is a content addressed store for backend lookups and media.
+// Content lives once in {Dir}/objects/{sha256[:2]}/{sha256}, while
+// {Dir}/index/{sha256(key)[:2]}/{sha256(key)}.json maps a ROM CRC or media URL
+// to its object and fetch time. A cache directory doubles as an offline
+// fixture directory.
+type Cache struct {
+	Dir     string
+	TTL     time.Duration // refetch entries older than this, 0 never expires
+	Offline bool          // serve stale entries and never call the backend
+
+	mu    sync.Mutex
+	Stats CacheStats
+}
+
+type CacheStats struct {
+	Hits      int // fresh entries served
+	Misses    int // keys fetched for the first time
+	Refreshed int // expired entries refetched with new content
+	Unchanged int // expired entries refetched with the same content
+	Stale     int // expired entries served offline or after a failed refetch
+}
+
+func (stats CacheStats) String() string {
+	return fmt.Sprintf("%v hits, %v misses, %v refreshed, %v unchanged, %v stale",
+		stats.Hits, stats.Misses, stats.Refreshed, stats.Unchanged, stats.Stale)
+}
+
+type cacheEntry struct {
+	Key      string    `json:"key"`
+	Object   string    `json:"object,omitempty"`
+	NotFound bool      `json:"notFound,omitempty"`
+	Fetched  time.Time `json:"fetched"`
+}
+
+func NewCache(dir string, ttl time.Duration) *Cache {
+	return &Cache{Dir: dir, TTL: ttl}
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (cache *Cache) indexPath(key string) string {
+	hash := sha256Hex([]byte(key))
+	return filepath.Join(cache.Dir, "index", hash[0:2], hash+".json")
+}
+
+func (cache *Cache) objectPath(object string) string {
+	return filepath.Join(cache.Dir, "objects", object[0:2], object)
+}
+
+// writeFile writes through a temp file so interrupted runs leave no partial entries
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (cache *Cache) readEntry(key string) (cacheEntry, []byte, bool) {
+	entry := cacheEntry{}
+	data, err := os.ReadFile(cache.indexPath(key))
+	if err != nil || json.Unmarshal(data, &entry) != nil || entry.Key != key {
+		return entry, nil, false
+	}
+	if entry.NotFound {
+		return entry, nil, true
+	}
+	content, err := os.ReadFile(cache.objectPath(entry.Object))
+	if err != nil || sha256Hex(content) != entry.Object {
+		return entry, nil, false
+	}
+	return entry, content, true
+}
+
+func (cache *Cache) writeEntry(key string, content []byte, notFound bool) (cacheEntry, error) {
+	entry := cacheEntry{Key: key, NotFound: notFound, Fetched: time.Now().UTC()}
+	if !notFound {
+		entry.Object = sha256Hex(content)
+		objectPath := cache.objectPath(entry.Object)
+		if _, err := os.Stat(objectPath); err != nil {
+			if err := writeFile(objectPath, content); err != nil {
+				return entry, err
+			}
+		}
+	}
+	data, err := json.MarshalIndent(entry, "", "\t")
+	if err != nil {
+		return entry, err
+	}
+	return entry, writeFile(cache.indexPath(key), data)
+}
+
+func (cache *Cache) count(stat *int) {
+	cache.mu.Lock()
+	*stat++
+	cache.mu.Unlock()
+}
+
+// Get serves key from cache while fresh, otherwise calls fetch and stores
+// the result. ErrNotFound results are cached too, other errors are not so
+// the next run retries them. Stale entries are served when fetch fails.
+func (cache *Cache) Get(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	entry, content, ok := cache.readEntry(key)
+	if ok && (cache.TTL <= 0 || time.Since(entry.Fetched) < cache.TTL) {
+		cache.count(&cache.Stats.Hits)
+		return cacheResult(entry, content)
+	}
+	if cache.Offline {
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", ErrOffline, key)
+		}
+		cache.count(&cache.Stats.Stale)
+		return cacheResult(entry, content)
+	}
+
+	fetched, err := fetch()
+	notFound := errors.Is(err, ErrNotFound)
+	if err != nil && !notFound {
+		if ok {
+			cache.count(&cache.Stats.Stale)
+			return cacheResult(entry, content)
+		}
+		return nil, err
+	}
+	newEntry, writeErr := cache.writeEntry(key, fetched, notFound)
+	if writeErr != nil {
+		fmt.Println("Unable to write cache entry", key, writeErr)
+	}
+	switch {
+	case !ok:
+		cache.count(&cache.Stats.Misses)
+	case newEntry.Object == entry.Object && newEntry.NotFound == entry.NotFound:
+		cache.count(&cache.Stats.Unchanged)
+	default:
+		cache.count(&cache.Stats.Refreshed)
+	}
+	return cacheResult(newEntry, fetched)
+}
+
+func cacheResult(entry cacheEntry, content []byte) ([]byte, error) {
+	if entry.NotFound {
+		return nil, ErrNotFound
+	}
+	return content, nil
+}
+
+// Query parameters that identify the user rather than the content
+var credentialParams = []string{"devid", "devpassword", "softname", "ssid", "sspassword"}
+
+// RedactURL drops credentials from a request URL, for keys, cache entries
+// and messages. Empty URLs stay empty.
+func RedactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || rawURL == "" {
+		return ""
+	}
+	query := parsed.Query()
+	for _, param := range credentialParams {
+		query.Del(param)
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// MediaKey keys a media URL without credentials, so caches are shared across users
+func MediaKey(mediaURL string) string {
+	if redacted := RedactURL(mediaURL); redacted != "" {
+		return "media:" + redacted
+	}
+	return "media:" + mediaURL
+}
+
+// CachedBackend puts a Cache in front of any Backend. Lookups are keyed by
+// backend name and ROM CRC, downloads by media URL.
+type CachedBackend struct {
+	Backend
+	Cache *Cache
+}
+
+func (cached *CachedBackend) Lookup(rom Rom) (Game, error) {
+	key := "lookup:" + cached.Backend.Name() + ":" + cacheKey(rom)
+	data, err := cached.Cache.Get(key, func() ([]byte, error) {
+		game, err := cached.Backend.Lookup(rom)
+		if err != nil {
+			return nil, err
+		}
+		// Entries are shared, the backend adds its own credentials on Download
+		game.ScreenshotURL = RedactURL(game.ScreenshotURL)
+		game.TitleScreenURL = RedactURL(game.TitleScreenURL)
+		return json.Marshal(game)
+	})
+	if err != nil {
+		return Game{}, err
+	}
+	game := Game{}
+	if err := json.Unmarshal(data, &game); err != nil {
+		return Game{}, fmt.Errorf("unable to read cached lookup for %v: %w", rom.Name, err)
+	}
+	return game, nil
+}
+
+func (cached *CachedBackend) Download(mediaURL string) ([]byte, error) {
+	return cached.Cache.Get(MediaKey(mediaURL), func() ([]byte, error) {
+		return cached.Backend.Download(mediaURL)
+	})
+}
@@ -0,0 +1,259 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fetcher counts calls and serves its current content or error
+type fetcher struct {
+	calls   int
+	content string
+	err     error
+}
+
+func (f *fetcher) fetch() ([]byte, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte(f.content), nil
+}
+
+// ageEntry moves an index entry's fetch time into the past
+func ageEntry(t *testing.T, cache *Cache, key string, age time.Duration) {
+	t.Helper()
+	path := cache.indexPath(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := cacheEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	entry.Fetched = time.Now().Add(-age)
+	if data, err = json.Marshal(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func cacheGet(t *testing.T, cache *Cache, key string, f *fetcher) string {
+	t.Helper()
+	data, err := cache.Get(key, f.fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCacheHitAndMiss(t *testing.T) {
+	cache := NewCache(t.TempDir(), time.Hour)
+	f := &fetcher{content: "v1"}
+	if got := cacheGet(t, cache, "lookup:a", f); got != "v1" {
+		t.Errorf("miss got %q", got)
+	}
+	if got := cacheGet(t, cache, "lookup:a", f); got != "v1" {
+		t.Errorf("hit got %q", got)
+	}
+	if f.calls != 1 {
+		t.Errorf("fetched %v times, want 1", f.calls)
+	}
+	if cache.Stats.Misses != 1 || cache.Stats.Hits != 1 {
+		t.Errorf("stats %v, want 1 miss and 1 hit", cache.Stats)
+	}
+
+	// A fresh Cache on the same directory resumes from disk
+	resumed := NewCache(cache.Dir, time.Hour)
+	if got := cacheGet(t, resumed, "lookup:a", f); got != "v1" || f.calls != 1 {
+		t.Errorf("resumed cache got %q after %v fetches", got, f.calls)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cache := NewCache(t.TempDir(), time.Hour)
+	f := &fetcher{content: "v1"}
+	cacheGet(t, cache, "lookup:a", f)
+
+	ageEntry(t, cache, "lookup:a", 30*time.Minute)
+	if got := cacheGet(t, cache, "lookup:a", f); got != "v1" || f.calls != 1 {
+		t.Errorf("entry within TTL got %q after %v fetches", got, f.calls)
+	}
+
+	ageEntry(t, cache, "lookup:a", 2*time.Hour)
+	if got := cacheGet(t, cache, "lookup:a", f); got != "v1" || f.calls != 2 {
+		t.Errorf("expired entry got %q after %v fetches, want a refetch", got, f.calls)
+	}
+	if cache.Stats.Unchanged != 1 {
+		t.Errorf("stats %v, want 1 unchanged", cache.Stats)
+	}
+
+	ageEntry(t, cache, "lookup:a", 2*time.Hour)
+	f.content = "v2"
+	if got := cacheGet(t, cache, "lookup:a", f); got != "v2" {
+		t.Errorf("refreshed entry got %q", got)
+	}
+	if cache.Stats.Refreshed != 1 {
+		t.Errorf("stats %v, want 1 refreshed", cache.Stats)
+	}
+
+	// TTL 0 never expires
+	cache.TTL = 0
+	ageEntry(t, cache, "lookup:a", 24*365*time.Hour)
+	if cacheGet(t, cache, "lookup:a", f); f.calls != 3 {
+		t.Errorf("TTL 0 refetched, %v fetches", f.calls)
+	}
+}
+
+func TestCacheStaleOnError(t *testing.T) {
+	cache := NewCache(t.TempDir(), time.Hour)
+	f := &fetcher{content: "v1"}
+	cacheGet(t, cache, "lookup:a", f)
+	ageEntry(t, cache, "lookup:a", 2*time.Hour)
+
+	f.err = errors.New("network down")
+	if got := cacheGet(t, cache, "lookup:a", f); got != "v1" {
+		t.Errorf("failed refetch got %q, want the stale entry", got)
+	}
+	if cache.Stats.Stale != 1 {
+		t.Errorf("stats %v, want 1 stale", cache.Stats)
+	}
+
+	// Errors without an entry are returned and not cached
+	if _, err := cache.Get("lookup:b", f.fetch); err == nil {
+		t.Error("failed fetch without an entry succeeded")
+	}
+	f.err = nil
+	if got := cacheGet(t, cache, "lookup:b", f); got != "v1" {
+		t.Errorf("retry after a failed fetch got %q", got)
+	}
+}
+
+func TestCacheNotFound(t *testing.T) {
+	cache := NewCache(t.TempDir(), time.Hour)
+	f := &fetcher{err: ErrNotFound}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get("lookup:missing", f.fetch); !errors.Is(err, ErrNotFound) {
+			t.Errorf("get %v returned %v, want ErrNotFound", i, err)
+		}
+	}
+	if f.calls != 1 {
+		t.Errorf("fetched %v times, want the not found result cached", f.calls)
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	cache := NewCache(t.TempDir(), time.Hour)
+	f := &fetcher{content: "v1"}
+	cacheGet(t, cache, "lookup:a", f)
+	ageEntry(t, cache, "lookup:a", 2*time.Hour)
+
+	cache.Offline = true
+	if got := cacheGet(t, cache, "lookup:a", f); got != "v1" || f.calls != 1 {
+		t.Errorf("offline got %q after %v fetches, want the stale entry", got, f.calls)
+	}
+	if _, err := cache.Get("lookup:b", f.fetch); !errors.Is(err, ErrOffline) || f.calls != 1 {
+		t.Errorf("offline miss returned %v after %v fetches, want ErrOffline", err, f.calls)
+	}
+}
+
+func TestCacheCorruptObject(t *testing.T) {
+	cache := NewCache(t.TempDir(), time.Hour)
+	f := &fetcher{content: "v1"}
+	cacheGet(t, cache, "lookup:a", f)
+	if err := os.WriteFile(cache.objectPath(sha256Hex([]byte("v1"))), []byte("torn"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := cacheGet(t, cache, "lookup:a", f); got != "v1" || f.calls != 2 {
+		t.Errorf("corrupt object got %q after %v fetches, want a refetch", got, f.calls)
+	}
+}
+
+func TestMediaKey(t *testing.T) {
+	a := MediaKey("https://neoclone.screenscraper.fr/api2/mediaJeu.php?devid=a&devpassword=b&softname=c&ssid=d&sspassword=e&jeuid=1234&media=ss(us)")
+	b := MediaKey("https://neoclone.screenscraper.fr/api2/mediaJeu.php?jeuid=1234&media=ss(us)&ssid=other&sspassword=other")
+	if a != b {
+		t.Errorf("keys differ by credentials: %v and %v", a, b)
+	}
+	for _, param := range credentialParams {
+		if strings.Contains(a, param+"=") {
+			t.Errorf("key %v keeps %v", a, param)
+		}
+	}
+	if c := MediaKey("https://neoclone.screenscraper.fr/api2/mediaJeu.php?jeuid=1234&media=sstitle(us)"); c == a {
+		t.Error("different media share a key")
+	}
+}
+
+// stubBackend answers every lookup with one game and counts calls
+type stubBackend struct {
+	lookups   int
+	downloads int
+}
+
+func (stub *stubBackend) Name() string { return "stub" }
+
+func (stub *stubBackend) Lookup(rom Rom) (Game, error) {
+	stub.lookups++
+	game := Game{ScreenshotURL: "http://media/ss?devid=dev&devpassword=devsecret&ssid=user&sspassword=usersecret"}
+	game.Name = strings.TrimSuffix(rom.Name, ".sfc")
+	return game, nil
+}
+
+func (stub *stubBackend) Download(url string) ([]byte, error) {
+	stub.downloads++
+	return []byte(url), nil
+}
+
+func TestCachedBackend(t *testing.T) {
+	stub := &stubBackend{}
+	cached := &CachedBackend{Backend: stub, Cache: NewCache(t.TempDir(), time.Hour)}
+	rom := Rom{Name: "Tetris.sfc", CRC: "AAAA0001"}
+	for i := 0; i < 2; i++ {
+		game, err := cached.Lookup(rom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if game.Name != "Tetris" || game.ScreenshotURL != "http://media/ss" {
+			t.Errorf("lookup %v got %+v", i, game)
+		}
+		if _, err := cached.Download(game.ScreenshotURL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Media URLs differing only by credentials share an entry
+	if _, err := cached.Download("http://media/ss?ssid=other"); err != nil {
+		t.Fatal(err)
+	}
+	if stub.lookups != 1 || stub.downloads != 1 {
+		t.Errorf("backend called %v lookups and %v downloads, want 1 of each", stub.lookups, stub.downloads)
+	}
+
+	// The cache is shared, no entry may hold a user's credentials
+	err := filepath.Walk(cached.Cache.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, secret := range []string{"devsecret", "usersecret", "user", "ssid", "sspassword"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("cache file %v holds %q", path, secret)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,79 @@
+package scraper
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter caps concurrent requests and requests per minute. Limits can be
+// lowered while running, as ScreenScraper reports the user quota with
+// every response.
+type Limiter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	threads int
+	perMin  int
+	active  int
+	recent  []time.Time
+}
+
+// NewLimiter allows threads concurrent requests, perMin 0 is unlimited
+func NewLimiter(threads int, perMin int) *Limiter {
+	if threads < 1 {
+		threads = 1
+	}
+	limiter := &Limiter{threads: threads, perMin: perMin}
+	limiter.cond = sync.NewCond(&limiter.mu)
+	return limiter
+}
+
+// SetQuota applies a server reported quota, values below 1 are ignored
+func (limiter *Limiter) SetQuota(threads int, perMin int) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if threads > 0 {
+		limiter.threads = threads
+	}
+	if perMin > 0 {
+		limiter.perMin = perMin
+	}
+	limiter.cond.Broadcast()
+}
+
+// Acquire blocks until a request may start, Release must follow
+func (limiter *Limiter) Acquire() {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	for {
+		for limiter.active >= limiter.threads {
+			limiter.cond.Wait()
+		}
+
+		now := time.Now()
+		kept := limiter.recent[:0]
+		for _, t := range limiter.recent {
+			if now.Sub(t) < time.Minute {
+				kept = append(kept, t)
+			}
+		}
+		limiter.recent = kept
+		if limiter.perMin <= 0 || len(limiter.recent) < limiter.perMin {
+			break
+		}
+
+		// Wait for the oldest request to leave the window
+		wait := time.Minute - now.Sub(limiter.recent[0])
+		limiter.mu.Unlock()
+		time.Sleep(wait)
+		limiter.mu.Lock()
+	}
+	limiter.active++
+	limiter.recent = append(limiter.recent, time.Now())
+}
+
+func (limiter *Limiter) Release() {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.active--
+	limiter.cond.Broadcast()
+}
@@ -0,0 +1,78 @@
+package scraper
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterThreads(t *testing.T) {
+	limiter := NewLimiter(2, 0)
+	var mu sync.Mutex
+	active, peak := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Acquire()
+			mu.Lock()
+			active++
+			if active > peak {
+				peak = active
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			active--
+			mu.Unlock()
+			limiter.Release()
+		}()
+	}
+	wg.Wait()
+	if peak != 2 {
+		t.Errorf("peak of %v concurrent requests, want 2", peak)
+	}
+}
+
+func TestLimiterSetQuotaWakesWaiters(t *testing.T) {
+	limiter := NewLimiter(1, 0)
+	limiter.Acquire()
+	acquired := make(chan struct{})
+	go func() {
+		limiter.Acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second request started over a 1 thread quota")
+	case <-time.After(50 * time.Millisecond):
+	}
+	limiter.SetQuota(2, 0)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("raising the thread quota did not start the waiting request")
+	}
+}
+
+func TestLimiterPerMinute(t *testing.T) {
+	limiter := NewLimiter(4, 3)
+	for i := 0; i < 3; i++ {
+		limiter.Acquire()
+		limiter.Release()
+	}
+	// Age the window so the oldest request leaves it shortly
+	limiter.mu.Lock()
+	for i := range limiter.recent {
+		limiter.recent[i] = time.Now().Add(-time.Minute + 100*time.Millisecond)
+	}
+	limiter.mu.Unlock()
+
+	start := time.Now()
+	limiter.Acquire()
+	limiter.Release()
+	if waited := time.Since(start); waited < 50*time.Millisecond || waited > 5*time.Second {
+		t.Errorf("fourth request waited %v, want about 100ms", waited)
+	}
+}
@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var reMediaFile = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// ReplayMediaName is the fixture filename for a mediaJeu.php request
+func ReplayMediaName(query map[string][]string) string {
+	get := func(key string) string {
+		if values := query[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	return reMediaFile.ReplaceAllString(get("jeuid")+"_"+get("media"), "_")
+}
+
+// ReplayHandler mocks the ScreenScraper API from recorded responses, for
+// scraping without network access or quota. A ScreenScraper RecordDir is
+// a valid fixture directory:
+//
+//	{dir}/jeuInfos/{crc}.json      jeuInfos.php responses by lowercase crc
+//	{dir}/mediaJeu/{jeuid}_{media} mediaJeu.php responses, see ReplayMediaName
+//
+// Unknown ROMs and media reply 404 like ScreenScraper.
+func ReplayHandler(dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		path := ""
+		switch filepath.Base(r.URL.Path) {
+		case "jeuInfos.php":
+			size, _ := strconv.Atoi(query.Get("romtaille"))
+			path = filepath.Join(dir, "jeuInfos", cacheKey(Rom{
+				Name: query.Get("romnom"),
+				CRC:  strings.ToLower(query.Get("crc")),
+				Size: size,
+			})+".json")
+		case "mediaJeu.php":
+			path = filepath.Join(dir, "mediaJeu", ReplayMediaName(query))
+		default:
+			http.Error(w, "Erreur : API inconnue", http.StatusBadRequest)
+			return
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			http.Error(w, "Erreur : Rom/Iso/Dossier non trouvée !", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	})
+}
@@ -0,0 +1,204 @@
+package scraper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/gamelist"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/rdb"
+)
+
+// ErrNotFound is returned by backends for ROMs they have no game for
+var ErrNotFound = errors.New("game not found")
+
+// ErrQuota is returned once the daily request quota is used up
+var ErrQuota = errors.New("scraper quota exceeded")
+
+// Rom is a touched ROM keyed by its RDB CRC, size and filename
+type Rom struct {
+	Name string
+	CRC  string
+	Size int
+}
+
+// Game is a scraped gamelist record with media still to download.
+// Game.Image and Game.Thumbnail are filled in once media is written.
+type Game struct {
+	gamelist.Game
+	ScreenshotURL  string
+	TitleScreenURL string
+	MediaFormat    string
+}
+
+// Backend looks up games for ROMs and downloads their media
+type Backend interface {
+	Name() string
+	Lookup(rom Rom) (Game, error)
+	Download(url string) ([]byte, error)
+}
+
+// TouchedRoms lists the empty ROM files touchndjson wrote to corePath/roms,
+// keyed with the CRC and size of their RDB entry
+func TouchedRoms(corePath string, rdbRoms []rdb.RdbJsonROM) ([]Rom, error) {
+	rdbByName := make(map[string]rdb.RdbJsonROM)
+	for _, rdbRom := range rdbRoms {
+		rdbByName[rdbRom.RomName] = rdbRom
+	}
+
+	entries, err := os.ReadDir(filepath.Join(corePath, "roms"))
+	if err != nil {
+		return nil, err
+	}
+	roms := []Rom{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		rom := Rom{Name: entry.Name()}
+		if rdbRom, ok := rdbByName[entry.Name()]; ok {
+			rom.CRC = strings.ToLower(rdbRom.CRC)
+			rom.Size = rdbRom.Size
+		}
+		roms = append(roms, rom)
+	}
+	return roms, nil
+}
+
+// Media folders relative to the core folder, as Skraper lays them out
+const (
+	ScreenshotDir  = "media/images"
+	TitleScreenDir = "media/titles"
+)
+
+// ScrapeOptions for Scrape
+type ScrapeOptions struct {
+	Threads int // concurrent lookups, backends throttle further to their own quotas
+	// RefreshMedia asks the backend again for media already on disk and
+	// rewrites files whose content changed. Worth it with a cached backend,
+	// otherwise existing media is kept without a download.
+	RefreshMedia bool
+}
+
+func writeMedia(backend Backend, options ScrapeOptions, corePath string, dir string, url string, rom Rom, format string) (string, error) {
+	if url == "" {
+		return "", nil
+	}
+	if format == "" {
+		format = "png"
+	}
+	name := strings.TrimSuffix(rom.Name, filepath.Ext(rom.Name)) + "." + format
+	relPath := filepath.ToSlash(filepath.Join(dir, name))
+	mediaPath := filepath.Join(corePath, relPath)
+
+	existing, statErr := os.ReadFile(mediaPath)
+	if !options.RefreshMedia && statErr == nil {
+		return "./" + relPath, nil
+	}
+
+	data, err := backend.Download(url)
+	if err != nil {
+		return "", err
+	}
+	if statErr == nil && bytes.Equal(existing, data) {
+		return "./" + relPath, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(mediaPath), os.ModePerm); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(mediaPath, data, 0644); err != nil {
+		return "", err
+	}
+	return "./" + relPath, nil
+}
+
+// scrapeRom looks up one ROM and downloads its media into corePath
+func scrapeRom(backend Backend, options ScrapeOptions, corePath string, rom Rom) (gamelist.Game, error) {
+	game, err := backend.Lookup(rom)
+	if err != nil {
+		return gamelist.Game{}, err
+	}
+	game.Path = "./roms/" + rom.Name
+
+	game.Image, err = writeMedia(backend, options, corePath, ScreenshotDir, game.ScreenshotURL, rom, game.MediaFormat)
+	if err != nil {
+		fmt.Println("Unable to download screenshot", rom.Name, err)
+	}
+	game.Thumbnail, err = writeMedia(backend, options, corePath, TitleScreenDir, game.TitleScreenURL, rom, game.MediaFormat)
+	if err != nil {
+		fmt.Println("Unable to download title screen", rom.Name, err)
+	}
+	return game.Game, nil
+}
+
+// Scrape looks up every ROM with up to options.Threads concurrent lookups.
+// ROMs without a match are skipped, and a used up quota stops the scrape
+// with the games found so far.
+func Scrape(backend Backend, corePath string, roms []Rom, options ScrapeOptions) ([]gamelist.Game, error) {
+	threads := options.Threads
+	if threads < 1 {
+		threads = 1
+	}
+	results := make([]*gamelist.Game, len(roms))
+	var quotaErr error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+
+	for w := 0; w < threads; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				mu.Lock()
+				stop := quotaErr != nil
+				mu.Unlock()
+				if stop {
+					continue
+				}
+
+				game, err := scrapeRom(backend, options, corePath, roms[i])
+				if errors.Is(err, ErrQuota) {
+					mu.Lock()
+					quotaErr = err
+					mu.Unlock()
+					continue
+				} else if errors.Is(err, ErrNotFound) {
+					fmt.Printf("Not found %v\n", roms[i].Name)
+					continue
+				} else if err != nil {
+					fmt.Printf("Unable to scrape %v: %v\n", roms[i].Name, err)
+					continue
+				}
+				fmt.Printf("Scraped %v -> %v\n", roms[i].Name, game.Name)
+				results[i] = &game
+			}
+		}()
+	}
+	for i := range roms {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	games := []gamelist.Game{}
+	for _, game := range results {
+		if game != nil {
+			games = append(games, *game)
+		}
+	}
+	return games, quotaErr
+}
+
+// WriteGamelist writes corePath/gamelist.xml for buildmgdb
+func WriteGamelist(corePath string, provider gamelist.Provider, games []gamelist.Game) error {
+	data, err := gamelist.MarshalGamelist(&gamelist.Gamelist{Provider: provider, Games: games})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(corePath, "gamelist.xml"), data, 0644)
+}
@@ -0,0 +1,107 @@
+package scraper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/gamelist"
+)
+
+var testRoms = []Rom{
+	{Name: "Tetris (USA).sfc", CRC: "aaaa0001", Size: 524288},
+	{Name: "Unknown (USA).sfc", CRC: "ffff0000", Size: 524288},
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestScrape(t *testing.T) {
+	ss := newReplayScraper(t)
+	corePath := t.TempDir()
+	games, err := Scrape(ss, corePath, testRoms, ScrapeOptions{Threads: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 {
+		t.Fatalf("Scrape returned %v games, want 1 with the unknown ROM skipped", len(games))
+	}
+	game := games[0]
+	if game.Path != "./roms/Tetris (USA).sfc" {
+		t.Errorf("Path %v", game.Path)
+	}
+	if game.Image != "./media/images/Tetris (USA).png" || game.Thumbnail != "./media/titles/Tetris (USA).png" {
+		t.Errorf("Image %v Thumbnail %v", game.Image, game.Thumbnail)
+	}
+	media := map[string]string{
+		game.Image:     "1234_ss_us_",
+		game.Thumbnail: "1234_sstitle_wor_",
+	}
+	for relPath, recorded := range media {
+		got := readFile(t, filepath.Join(corePath, relPath))
+		if want := readFile(t, filepath.Join(replayDir, "mediaJeu", recorded)); !bytes.Equal(got, want) {
+			t.Errorf("%v does not match recorded %v", relPath, recorded)
+		}
+	}
+}
+
+func TestScrapeRefreshMedia(t *testing.T) {
+	ss := newReplayScraper(t)
+	corePath := t.TempDir()
+	screenshot := filepath.Join(corePath, ScreenshotDir, "Tetris (USA).png")
+	if err := os.MkdirAll(filepath.Dir(screenshot), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(screenshot, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Scrape(ss, corePath, testRoms[:1], ScrapeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, screenshot); string(got) != "old" {
+		t.Error("existing media was replaced without RefreshMedia")
+	}
+
+	if _, err := Scrape(ss, corePath, testRoms[:1], ScrapeOptions{RefreshMedia: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, screenshot); !bytes.Equal(got, readFile(t, filepath.Join(replayDir, "mediaJeu", "1234_ss_us_"))) {
+		t.Error("existing media was not refreshed with RefreshMedia")
+	}
+}
+
+func TestWriteGamelist(t *testing.T) {
+	ss := newReplayScraper(t)
+	corePath := t.TempDir()
+	games, err := Scrape(ss, corePath, testRoms, ScrapeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := gamelist.Provider{System: "SNES", Software: "test", Database: ss.Name()}
+	if err := WriteGamelist(corePath, provider, games); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, source, err := gamelist.LoadSource(corePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Name() != (gamelist.EmulationStation{}).Name() {
+		t.Errorf("gamelist.xml loaded as %v", source.Name())
+	}
+	if len(loaded.Games) != 1 {
+		t.Fatalf("gamelist.xml has %v games, want 1", len(loaded.Games))
+	}
+	got := loaded.Games[0]
+	if got.ID != "1234" || got.Name != "Tetris" || got.Path != games[0].Path || got.Image != games[0].Image {
+		t.Errorf("gamelist.xml game %+v, want %+v", got, games[0])
+	}
+}
@@ -0,0 +1,389 @@
+package scraper
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/gamelist"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/romname"
+)
+
+const ScreenScraperBaseURL = "https://api.screenscraper.fr/api2"
+
+// Credentials for the ScreenScraper API, dev credentials identify the
+// software and user credentials raise the thread and request quotas
+type Credentials struct {
+	DevID       string
+	DevPassword string
+	SoftName    string
+	User        string
+	Password    string
+}
+
+// ScreenScraper is a jeuInfos client. Raw responses are recorded in
+// RecordDir/jeuInfos/{crc}.json and RecordDir/mediaJeu, the layout
+// ReplayHandler serves. Recordings are never read back here, use a
+// CachedBackend for resumable scrapes.
+type ScreenScraper struct {
+	BaseURL     string
+	Client      *http.Client
+	Credentials Credentials
+	SystemID    int
+	Regions     []string // name, date and media region preference
+	Languages   []string // synopsis and genre language preference
+	RecordDir   string   // raw response recordings, "" disables
+	Retries     int      // retries on HTTP 429 before giving up
+	Limiter     *Limiter
+}
+
+func NewScreenScraper(credentials Credentials, systemID int) *ScreenScraper {
+	ss := &ScreenScraper{
+		BaseURL:     ScreenScraperBaseURL,
+		Client:      &http.Client{Timeout: 60 * time.Second},
+		Credentials: credentials,
+		SystemID:    systemID,
+		Retries:     3,
+		// Anonymous quota until the first response reports the user's
+		Limiter: NewLimiter(1, 20),
+	}
+	ss.SetPreference(romname.DefaultPreference)
+	return ss
+}
+
+// ScreenScraper region codes for No-Intro region names
+var ssRegions = map[string]string{
+	"World": "wor", "USA": "us", "Europe": "eu", "Japan": "jp", "Asia": "asi",
+	"Australia": "au", "Brazil": "br", "Canada": "ca", "China": "cn", "Denmark": "dk",
+	"Finland": "fi", "France": "fr", "Germany": "de", "Greece": "gr", "Israel": "il",
+	"Italy": "it", "Korea": "kr", "Netherlands": "nl", "New Zealand": "nz",
+	"Norway": "no", "Poland": "pl", "Portugal": "pt", "Russia": "ru", "Spain": "sp",
+	"Sweden": "se", "Taiwan": "tw", "UK": "uk",
+}
+
+// SetPreference orders names, dates and media by the same region and
+// language preference touchndjson picks ROMs with. ScreenScraper's own
+// "ss" entries are the fallback after the preferred regions.
+func (ss *ScreenScraper) SetPreference(pref romname.Preference) {
+	ss.Regions = []string{}
+	for _, region := range pref.Regions {
+		code, ok := ssRegions[region]
+		if !ok {
+			code = strings.ToLower(region)
+		}
+		ss.Regions = append(ss.Regions, code)
+	}
+	ss.Regions = append(ss.Regions, "ss")
+	ss.Languages = []string{}
+	for _, language := range pref.Languages {
+		ss.Languages = append(ss.Languages, strings.ToLower(language))
+	}
+}
+
+func (ss *ScreenScraper) Name() string {
+	return "ScreenScraper.fr"
+}
+
+// ScreenScraper reports numbers as strings or numbers depending on the field
+type ssInt int
+
+func (value *ssInt) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), "\"")
+	if text == "" || text == "null" {
+		*value = 0
+		return nil
+	}
+	parsed, err := strconv.Atoi(text)
+	if err != nil {
+		return err
+	}
+	*value = ssInt(parsed)
+	return nil
+}
+
+type ssText struct {
+	Region string `json:"region"`
+	Langue string `json:"langue"`
+	Text   string `json:"text"`
+}
+
+type ssMedia struct {
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Region string `json:"region"`
+	Format string `json:"format"`
+}
+
+type jeuInfosResponse struct {
+	Response struct {
+		SSUser struct {
+			MaxThreads        ssInt `json:"maxthreads"`
+			MaxRequestsPerMin ssInt `json:"maxrequestspermin"`
+		} `json:"ssuser"`
+		Jeu struct {
+			ID          string   `json:"id"`
+			Noms        []ssText `json:"noms"`
+			Synopsis    []ssText `json:"synopsis"`
+			Dates       []ssText `json:"dates"`
+			Developpeur ssText   `json:"developpeur"`
+			Editeur     ssText   `json:"editeur"`
+			Joueurs     ssText   `json:"joueurs"`
+			Note        ssText   `json:"note"`
+			Genres      []struct {
+				Noms []ssText `json:"noms"`
+			} `json:"genres"`
+			Medias []ssMedia `json:"medias"`
+		} `json:"jeu"`
+	} `json:"response"`
+}
+
+// cacheKey is the ROM CRC, ROMs without one are keyed by name and size
+func cacheKey(rom Rom) string {
+	if rom.CRC != "" {
+		return strings.ToLower(rom.CRC)
+	}
+	sum := md5.Sum([]byte(fmt.Sprintf("%v:%v", rom.Name, rom.Size)))
+	return "name-" + hex.EncodeToString(sum[:])
+}
+
+func (ss *ScreenScraper) recordPath(rom Rom) string {
+	if ss.RecordDir == "" {
+		return ""
+	}
+	return filepath.Join(ss.RecordDir, "jeuInfos", cacheKey(rom)+".json")
+}
+
+func (ss *ScreenScraper) query() url.Values {
+	query := url.Values{}
+	query.Set("devid", ss.Credentials.DevID)
+	query.Set("devpassword", ss.Credentials.DevPassword)
+	query.Set("softname", ss.Credentials.SoftName)
+	query.Set("ssid", ss.Credentials.User)
+	query.Set("sspassword", ss.Credentials.Password)
+	query.Set("output", "json")
+	return query
+}
+
+// get throttles, retries on 429 and maps ScreenScraper status codes
+func (ss *ScreenScraper) get(requestURL string) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		ss.Limiter.Acquire()
+		resp, err := ss.Client.Get(requestURL)
+		if err != nil {
+			ss.Limiter.Release()
+			// url.Error quotes the request URL, credentials included
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				urlErr.URL = RedactURL(urlErr.URL)
+			}
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		ss.Limiter.Release()
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return body, nil
+		case http.StatusNotFound:
+			return nil, ErrNotFound
+		case http.StatusTooManyRequests:
+			if attempt < ss.Retries {
+				time.Sleep(time.Duration(attempt+1) * 5 * time.Second)
+				continue
+			}
+		case 430, 431:
+			return nil, fmt.Errorf("%w: %v", ErrQuota, strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("screenscraper HTTP %v: %v", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
+
+// Credential values in the media URLs of a raw response, JSON escaped or not
+var reCredentialValue = regexp.MustCompile(`([?&]|\\u0026)(` + strings.Join(credentialParams, "|") + `)=[^&"\\]*`)
+
+// redactRecording blanks credentials in a raw response, recordings are
+// shared as fixtures and Download adds the current credentials back
+func redactRecording(data []byte) []byte {
+	return reCredentialValue.ReplaceAll(data, []byte("${1}${2}="))
+}
+
+// JeuInfos returns the raw jeuInfos response for a ROM
+func (ss *ScreenScraper) JeuInfos(rom Rom) ([]byte, error) {
+	query := ss.query()
+	query.Set("romtype", "rom")
+	if ss.SystemID > 0 {
+		query.Set("systemeid", strconv.Itoa(ss.SystemID))
+	}
+	if rom.CRC != "" {
+		query.Set("crc", strings.ToUpper(rom.CRC))
+	}
+	if rom.Size > 0 {
+		query.Set("romtaille", strconv.Itoa(rom.Size))
+	}
+	query.Set("romnom", rom.Name)
+
+	data, err := ss.get(ss.BaseURL + "/jeuInfos.php?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if recordPath := ss.recordPath(rom); recordPath != "" {
+		if err := os.MkdirAll(filepath.Dir(recordPath), os.ModePerm); err != nil {
+			return data, err
+		}
+		if err := os.WriteFile(recordPath, redactRecording(data), 0644); err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+func (ss *ScreenScraper) Lookup(rom Rom) (Game, error) {
+	data, err := ss.JeuInfos(rom)
+	if err != nil {
+		return Game{}, err
+	}
+	response := jeuInfosResponse{}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return Game{}, fmt.Errorf("unable to parse jeuInfos for %v: %w", rom.Name, err)
+	}
+	ssUser := response.Response.SSUser
+	ss.Limiter.SetQuota(int(ssUser.MaxThreads), int(ssUser.MaxRequestsPerMin))
+
+	jeu := response.Response.Jeu
+	if jeu.ID == "" {
+		return Game{}, ErrNotFound
+	}
+	note, _ := strconv.ParseFloat(jeu.Note.Text, 64)
+	genre := ""
+	if len(jeu.Genres) > 0 {
+		genre = ss.byLanguage(jeu.Genres[0].Noms)
+	}
+
+	game := Game{
+		Game: gamelist.Game{
+			ID:          jeu.ID,
+			Source:      ss.Name(),
+			Name:        ss.byRegion(jeu.Noms),
+			Desc:        ss.byLanguage(jeu.Synopsis),
+			Rating:      gamelist.ESRating(note, 20),
+			ReleaseDate: gamelist.ESDate(ss.byRegion(jeu.Dates)),
+			Developer:   jeu.Developpeur.Text,
+			Publisher:   jeu.Editeur.Text,
+			Genre:       genre,
+			Players:     jeu.Joueurs.Text,
+		},
+	}
+	if screenshot, ok := ss.media(jeu.Medias, "ss"); ok {
+		game.ScreenshotURL = screenshot.URL
+		game.MediaFormat = screenshot.Format
+	}
+	if titleScreen, ok := ss.media(jeu.Medias, "sstitle"); ok {
+		game.TitleScreenURL = titleScreen.URL
+		if game.MediaFormat == "" {
+			game.MediaFormat = titleScreen.Format
+		}
+	}
+	return game, nil
+}
+
+func (ss *ScreenScraper) byRegion(texts []ssText) string {
+	for _, region := range ss.Regions {
+		for _, text := range texts {
+			if text.Region == region {
+				return text.Text
+			}
+		}
+	}
+	if len(texts) > 0 {
+		return texts[0].Text
+	}
+	return ""
+}
+
+func (ss *ScreenScraper) byLanguage(texts []ssText) string {
+	for _, language := range ss.Languages {
+		for _, text := range texts {
+			if text.Langue == language {
+				return text.Text
+			}
+		}
+	}
+	if len(texts) > 0 {
+		return texts[0].Text
+	}
+	return ""
+}
+
+func (ss *ScreenScraper) media(medias []ssMedia, mediaType string) (ssMedia, bool) {
+	regions := append(append([]string{}, ss.Regions...), "")
+	for _, region := range regions {
+		for _, media := range medias {
+			if media.Type == mediaType && media.Region == region && media.URL != "" {
+				return media, true
+			}
+		}
+	}
+	for _, media := range medias {
+		if media.Type == mediaType && media.URL != "" {
+			return media, true
+		}
+	}
+	return ssMedia{}, false
+}
+
+// withCredentials sets this scraper's credentials on a media URL, cached
+// and recorded URLs have theirs removed
+func (ss *ScreenScraper) withCredentials(mediaURL string) string {
+	parsed, err := url.Parse(mediaURL)
+	if err != nil {
+		return mediaURL
+	}
+	query := parsed.Query()
+	credentials := ss.query()
+	for _, param := range credentialParams {
+		query.Set(param, credentials.Get(param))
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// Download fetches a media URL. Against a non default BaseURL, such as a
+// ReplayHandler, media URLs are redirected to it as well.
+func (ss *ScreenScraper) Download(mediaURL string) ([]byte, error) {
+	if ss.BaseURL != ScreenScraperBaseURL {
+		if i := strings.Index(mediaURL, "/mediaJeu.php"); i >= 0 {
+			mediaURL = ss.BaseURL + mediaURL[i:]
+		}
+	}
+	data, err := ss.get(ss.withCredentials(mediaURL))
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("media %w", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if ss.RecordDir != "" {
+		if parsed, err := url.Parse(mediaURL); err == nil {
+			recordPath := filepath.Join(ss.RecordDir, "mediaJeu", ReplayMediaName(parsed.Query()))
+			if err := os.MkdirAll(filepath.Dir(recordPath), os.ModePerm); err == nil {
+				os.WriteFile(recordPath, data, 0644)
+			}
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,182 @@
+package scraper
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/gamelist"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/romname"
+)
+
+// Recorded responses for one ROM, Tetris with CRC aaaa0001
+const replayDir = "testdata/replay"
+
+var testCredentials = Credentials{
+	DevID:       "dev",
+	DevPassword: "devsecret",
+	SoftName:    "test",
+	User:        "user",
+	Password:    "usersecret",
+}
+
+func newReplayScraper(t *testing.T) *ScreenScraper {
+	t.Helper()
+	server := httptest.NewServer(ReplayHandler(replayDir))
+	t.Cleanup(server.Close)
+	ss := NewScreenScraper(testCredentials, 4)
+	ss.BaseURL = server.URL
+	return ss
+}
+
+func TestLookup(t *testing.T) {
+	ss := newReplayScraper(t)
+	game, err := ss.Lookup(Rom{Name: "Tetris (USA).sfc", CRC: "AAAA0001", Size: 524288})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := gamelist.Game{
+		ID:          "1234",
+		Source:      "ScreenScraper.fr",
+		Name:        "Tetris",
+		Desc:        "Blocks",
+		Rating:      gamelist.ESRating(16, 20),
+		ReleaseDate: gamelist.ESDate("1991-08"),
+		Developer:   "Nintendo",
+		Publisher:   "Nintendo",
+		Genre:       "Puzzle",
+		Players:     "1-2",
+	}
+	if game.Game != want {
+		t.Errorf("Lookup game\n got %+v\nwant %+v", game.Game, want)
+	}
+	if !strings.Contains(game.ScreenshotURL, "media=ss(us)") {
+		t.Errorf("ScreenshotURL %v, want the us screenshot", game.ScreenshotURL)
+	}
+	if !strings.Contains(game.TitleScreenURL, "media=sstitle(wor)") {
+		t.Errorf("TitleScreenURL %v, want the wor title screen", game.TitleScreenURL)
+	}
+	if game.MediaFormat != "png" {
+		t.Errorf("MediaFormat %v, want png", game.MediaFormat)
+	}
+	// The response reports a 2 thread, 60 per minute quota
+	if ss.Limiter.threads != 2 || ss.Limiter.perMin != 60 {
+		t.Errorf("Limiter quota %v threads %v per minute, want 2 and 60", ss.Limiter.threads, ss.Limiter.perMin)
+	}
+}
+
+func TestLookupPreference(t *testing.T) {
+	ss := newReplayScraper(t)
+	ss.SetPreference(romname.ParsePreference("Japan,USA", "Fr,En"))
+	game, err := ss.Lookup(Rom{Name: "Tetris (Japan).sfc", CRC: "aaaa0001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if game.Name != "Tetris JP" || game.Desc != "Blocs" {
+		t.Errorf("Lookup with Japan,USA and Fr,En got %q %q, want %q %q", game.Name, game.Desc, "Tetris JP", "Blocs")
+	}
+}
+
+func TestLookupNotFound(t *testing.T) {
+	ss := newReplayScraper(t)
+	if _, err := ss.Lookup(Rom{Name: "Unknown (USA).sfc", CRC: "ffff0000"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup of unknown ROM got %v, want ErrNotFound", err)
+	}
+}
+
+func TestDownloadReplay(t *testing.T) {
+	ss := newReplayScraper(t)
+	game, err := ss.Lookup(Rom{Name: "Tetris (USA).sfc", CRC: "aaaa0001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ss.Download(game.ScreenshotURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "\x89PNG") {
+		t.Errorf("Download returned %q, want the recorded PNG", data)
+	}
+}
+
+func TestDownloadAddsCredentials(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte("\x89PNG"))
+	}))
+	defer server.Close()
+	ss := NewScreenScraper(testCredentials, 4)
+	ss.BaseURL = server.URL
+
+	// Cached URLs are redacted, or carry another user's credentials
+	if _, err := ss.Download(ScreenScraperBaseURL + "/mediaJeu.php?ssid=other&sspassword=other&jeuid=1234&media=ss(us)"); err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("ssid") != testCredentials.User || query.Get("sspassword") != testCredentials.Password ||
+		query.Get("devid") != testCredentials.DevID || query.Get("jeuid") != "1234" {
+		t.Errorf("media requested with %v, want this scraper's credentials", query)
+	}
+}
+
+func TestRecordHidesCredentials(t *testing.T) {
+	// ScreenScraper echoes the request credentials in media URLs
+	body := `{"response":{"jeu":{"id":"1234","noms":[{"region":"us","text":"Tetris"}],"medias":[` +
+		`{"type":"ss","region":"us","url":"https://x/mediaJeu.php?devid=dev&devpassword=devsecret&ssid=user&sspassword=usersecret&jeuid=1234&media=ss(us)","format":"png"},` +
+		`{"type":"sstitle","region":"us","url":"https://x/mediaJeu.php?jeuid=1234\u0026ssid=user\u0026sspassword=usersecret\u0026media=sstitle(us)","format":"png"}]}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	ss := NewScreenScraper(testCredentials, 4)
+	ss.BaseURL = server.URL
+	ss.RecordDir = t.TempDir()
+
+	rom := Rom{Name: "Tetris (USA).sfc", CRC: "aaaa0001"}
+	if _, err := ss.Lookup(rom); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(ss.recordPath(rom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"devsecret", "usersecret", "=user", "=dev"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("recording holds %q: %s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), "jeuid=1234") || !strings.Contains(string(data), `\u0026media=sstitle(us)`) {
+		t.Errorf("recording lost its media parameters: %s", data)
+	}
+}
+
+func TestRequestErrorHidesCredentials(t *testing.T) {
+	server := httptest.NewServer(ReplayHandler(replayDir))
+	baseURL := server.URL
+	server.Close()
+
+	ss := NewScreenScraper(testCredentials, 4)
+	ss.BaseURL = baseURL
+	ss.Retries = 0
+	_, err := ss.Lookup(Rom{Name: "Tetris (USA).sfc", CRC: "aaaa0001"})
+	if err == nil {
+		t.Fatal("Lookup against a closed server succeeded")
+	}
+	for _, secret := range []string{testCredentials.DevPassword, testCredentials.Password, "ssid=", "devid="} {
+		if strings.Contains(err.Error(), secret) {
+			t.Errorf("error %q contains %q", err, secret)
+		}
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	got := RedactURL("https://api.screenscraper.fr/api2/mediaJeu.php?devid=d&devpassword=p&softname=s&ssid=u&sspassword=w&jeuid=1&media=ss(us)")
+	want := "https://api.screenscraper.fr/api2/mediaJeu.php?jeuid=1&media=ss%28us%29"
+	if got != want {
+		t.Errorf("RedactURL got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+type collectionResponse struct {
+	ID           string        `json:"id"`
+	Info         mgdb.MGDBInfo `json:"info"`
+	GameCount    int           `json:"game_count"`
+	IndexedCount int           `json:"indexed_count"`
+}
+
+type gamesResponse struct {
+	Collection string           `json:"collection"`
+	Page       int              `json:"page"`
+	PerPage    int              `json:"per_page"`
+	Total      int              `json:"total"`
+	Games      []sqlite.GameRow `json:"games"`
+}
+
+type gameResponse struct {
+	Collection string            `json:"collection"`
+	Game       sqlite.GameRow    `json:"game"`
+	Roms       []mgdb.IndexedRom `json:"roms,omitempty"`
+	KnownRoms  []mgdb.RomTag     `json:"known_roms,omitempty"`
+}
+
+type searchResult struct {
+	Collection string         `json:"collection"`
+	Game       sqlite.GameRow `json:"game"`
+}
+
+type searchResponse struct {
+	Query   string         `json:"query"`
+	Results []searchResult `json:"results"`
+}
+
+type nameResponse struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func (collection *Collection) summary() (collectionResponse, error) {
+	response := collectionResponse{ID: collection.ID, Info: collection.Info}
+	_, total, err := sqlite.QueryGames(collection.db, sqlite.GameFilter{Limit: 1})
+	if err != nil {
+		return response, err
+	}
+	indexed := true
+	_, indexedTotal, err := sqlite.QueryGames(collection.db, sqlite.GameFilter{Limit: 1, Indexed: &indexed})
+	response.GameCount = total
+	response.IndexedCount = indexedTotal
+	return response, err
+}
+
+func (server *Server) listCollections(w http.ResponseWriter) {
+	collections := []collectionResponse{}
+	for _, id := range server.ids {
+		summary, err := server.collections[id].summary()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		collections = append(collections, summary)
+	}
+	writeJSON(w, http.StatusOK, collections)
+}
+
+func (server *Server) getCollection(w http.ResponseWriter, collection *Collection) {
+	summary, err := collection.summary()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// Positive int query parameter, fallback when absent
+func queryInt(r *http.Request, key string, fallback int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%v must be a positive integer", key)
+	}
+	return n, nil
+}
+
+func (server *Server) listGames(w http.ResponseWriter, r *http.Request, collection *Collection) {
+	query := r.URL.Query()
+	page, err := queryInt(r, "page", 1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	perPage, err := queryInt(r, "per_page", DefaultPerPage)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if perPage > MaxPerPage {
+		perPage = MaxPerPage
+	}
+
+	filter := sqlite.GameFilter{
+		Genre:     query.Get("genre"),
+		Developer: query.Get("developer"),
+		Publisher: query.Get("publisher"),
+		Search:    strings.TrimSpace(query.Get("q")),
+		Limit:     perPage,
+		Offset:    (page - 1) * perPage,
+	}
+	if indexed := query.Get("indexed"); indexed != "" {
+		value, err := strconv.ParseBool(indexed)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("indexed must be true or false"))
+			return
+		}
+		filter.Indexed = &value
+	}
+
+	games, total, err := sqlite.QueryGames(collection.db, filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, gamesResponse{
+		Collection: collection.ID,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		Games:      games,
+	})
+}
+
+func (server *Server) getGame(w http.ResponseWriter, collection *Collection, gameIDParam string, withRoms bool) {
+	gameID, err := strconv.Atoi(gameIDParam)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("game id must be an integer"))
+		return
+	}
+	game, err := sqlite.GetGameRow(collection.db, gameID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown game %v", gameID))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := gameResponse{Collection: collection.ID, Game: game}
+	if withRoms {
+		if response.Roms, err = sqlite.GetGameIndexedRoms(collection.db, gameID); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if response.KnownRoms, err = sqlite.GetGameRomTags(collection.db, gameID); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (server *Server) listNames(w http.ResponseWriter, collection *Collection, table string) {
+	names := []nameResponse{}
+	var err error
+	switch table {
+	case "genres":
+		var genres []mgdb.Genre
+		genres, err = sqlite.GetGenres(collection.db)
+		for _, genre := range genres {
+			names = append(names, nameResponse{ID: genre.GenreID, Name: genre.Name})
+		}
+	case "developers":
+		var developers []mgdb.Developer
+		developers, err = sqlite.GetDevelopers(collection.db)
+		for _, developer := range developers {
+			names = append(names, nameResponse{ID: developer.DeveloperID, Name: developer.Name})
+		}
+	case "publishers":
+		var publishers []mgdb.Publisher
+		publishers, err = sqlite.GetPublishers(collection.db)
+		for _, publisher := range publishers {
+			names = append(names, nameResponse{ID: publisher.PublisherID, Name: publisher.Name})
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, names)
+}
+
+// getImage serves blob bytes. Blobs are addressed by their MD5, so they
+// never change and can be cached forever.
+func (server *Server) getImage(w http.ResponseWriter, r *http.Request, collection *Collection, hash string) {
+	etag := `"` + hash + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	blob, err := sqlite.GetImageBlob(collection.db, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown image %v", hash))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(blob.Bytes))
+	w.Header().Set("Content-Length", strconv.Itoa(len(blob.Bytes)))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(blob.Bytes)
+	}
+}
+
+// search matches game names across all collections
+func (server *Server) search(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, errors.New("q is required"))
+		return
+	}
+	limit, err := queryInt(r, "limit", DefaultPerPage)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if limit > MaxPerPage {
+		limit = MaxPerPage
+	}
+
+	response := searchResponse{Query: q, Results: []searchResult{}}
+	for _, id := range server.ids {
+		remaining := limit - len(response.Results)
+		if remaining <= 0 {
+			break
+		}
+		games, _, err := sqlite.QueryGames(server.collections[id].db, sqlite.GameFilter{Search: q, Limit: remaining})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, game := range games {
+			response.Results = append(response.Results, searchResult{Collection: id, Game: game})
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
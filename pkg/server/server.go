@@ -0,0 +1,150 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 500
+)
+
+// Collection is one read-only MGDB, addressed by its GamesFolder
+type Collection struct {
+	ID   string
+	Path string
+	Info mgdb.MGDBInfo
+	db   *sql.DB
+}
+
+// Server serves MGDBs as JSON under /api:
+//
+//	GET /api/collections
+//	GET /api/collections/{id}
+//	GET /api/collections/{id}/games?page=&per_page=&genre=&developer=&publisher=&indexed=&q=
+//	GET /api/collections/{id}/games/{gameId}
+//	GET /api/collections/{id}/games/{gameId}/roms
+//	GET /api/collections/{id}/genres, /developers, /publishers
+//	GET /api/collections/{id}/images/{hash}
+//	GET /api/search?q=&limit=
+type Server struct {
+	collections map[string]*Collection
+	ids         []string
+}
+
+// New opens every MGDB read-only
+func New(paths []string) (*Server, error) {
+	server := &Server{collections: make(map[string]*Collection)}
+	for _, path := range paths {
+		db, err := sqlite.OpenMGDB(path)
+		if err != nil {
+			server.Close()
+			return nil, fmt.Errorf("unable to open %v: %w", path, err)
+		}
+		info, err := sqlite.GetMGDBInfo(db)
+		if err != nil {
+			db.Close()
+			server.Close()
+			return nil, fmt.Errorf("unable to read MGDBInfo of %v: %w", path, err)
+		}
+
+		id := info.GamesFolder
+		if id == "" {
+			id = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		base := id
+		for n := 2; server.collections[id] != nil; n++ {
+			id = fmt.Sprintf("%v-%v", base, n)
+		}
+		server.collections[id] = &Collection{ID: id, Path: path, Info: info, db: db}
+		server.ids = append(server.ids, id)
+	}
+	sort.Strings(server.ids)
+	return server, nil
+}
+
+func (server *Server) Close() {
+	for _, collection := range server.collections {
+		collection.db.Close()
+	}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	encoder.Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+var (
+	errNotFound         = errors.New("not found")
+	errMethodNotAllowed = errors.New("method not allowed")
+)
+
+// ServeHTTP routes by hand, Go 1.18 muxes have no path patterns
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "api" {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	if parts[1] == "search" && len(parts) == 2 {
+		server.search(w, r)
+		return
+	}
+	if parts[1] != "collections" {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	if len(parts) == 2 {
+		server.listCollections(w)
+		return
+	}
+
+	collection, ok := server.collections[parts[2]]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown collection %v", parts[2]))
+		return
+	}
+	switch {
+	case len(parts) == 3:
+		server.getCollection(w, collection)
+	case len(parts) == 4 && parts[3] == "games":
+		server.listGames(w, r, collection)
+	case len(parts) == 5 && parts[3] == "games":
+		server.getGame(w, collection, parts[4], false)
+	case len(parts) == 6 && parts[3] == "games" && parts[5] == "roms":
+		server.getGame(w, collection, parts[4], true)
+	case len(parts) == 4 && (parts[3] == "genres" || parts[3] == "developers" || parts[3] == "publishers"):
+		server.listNames(w, collection, parts[3])
+	case len(parts) == 5 && parts[3] == "images":
+		server.getImage(w, r, collection, parts[4])
+	default:
+		writeError(w, http.StatusNotFound, errNotFound)
+	}
+}
@@ -0,0 +1,188 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+type gcQuery struct {
+	table string
+	where string
+}
+
+// Unused rows per table, ID 0 ~Unknown placeholders are always kept
+var gcQueries = []gcQuery{
+	{"Genre", "GenreID != 0 and GenreID not in (select GenreID from main.Game)"},
+	{"Developer", "DeveloperID != 0 and DeveloperID not in (select DeveloperID from main.Game)"},
+	{"Publisher", "PublisherID != 0 and PublisherID not in (select PublisherID from main.Game)"},
+}
+
+// ROM mappings left without a game, in delete order. Each query also
+// matches rows the ones before it delete, so dry runs count the same rows.
+// RomTag rows of CRC-less sets are kept, they are keyed by RomName.
+var gcRomQueries = []gcQuery{
+	{"SlugRom", "GameID not in (select GameID from main.Game)"},
+	{"RomCrc", "Slug not in (select Slug from main.SlugRom where GameID in (select GameID from main.Game))"},
+	{"RomTag", "CRC32 != '' and CRC32 not in (select c.CRC32 from main.RomCrc c join main.SlugRom s on s.Slug = c.Slug " +
+		"where s.GameID in (select GameID from main.Game))"},
+}
+
+// Merged MGDBs key slugs and CRCs per collection, GameID 0 is shared
+var gcMergedRomQueries = []gcQuery{
+	{"SlugRom", "GameID != 0 and not exists (select 1 from main.Game g " +
+		"where g.GameID = SlugRom.GameID and g.CollectionID = SlugRom.CollectionID)"},
+	{"RomCrc", "not exists (select 1 from main.SlugRom s where s.Slug = RomCrc.Slug and s.CollectionID = RomCrc.CollectionID " +
+		"and (s.GameID = 0 or exists (select 1 from main.Game g where g.GameID = s.GameID and g.CollectionID = s.CollectionID)))"},
+	{"RomTag", "CRC32 != '' and not exists (select 1 from main.RomCrc c join main.SlugRom s " +
+		"on s.Slug = c.Slug and s.CollectionID = c.CollectionID " +
+		"where c.CRC32 = RomTag.CRC32 and c.CollectionID = RomTag.CollectionID " +
+		"and (s.GameID = 0 or exists (select 1 from main.Game g where g.GameID = s.GameID and g.CollectionID = s.CollectionID)))"},
+}
+
+const gcBlobWhere = "Hash not in (select ScreenshotHash from main.Game where ScreenshotHash is not null) " +
+	"and Hash not in (select TitleScreenHash from main.Game where TitleScreenHash is not null)"
+
+type GCTable struct {
+	Table  string // media.ImageBlob for the media pack
+	Rows   int
+	Unused int
+	Bytes  int64 // ImageBlob payload of unused rows
+}
+
+type GCReport struct {
+	Path       string
+	MediaPath  string // empty without a media pack
+	Applied    bool
+	Tables     []GCTable
+	SizeBefore int64 // MGDB and media pack file sizes
+	SizeAfter  int64 // only measured when applied
+}
+
+// UnusedBytes is the blob payload the collection frees, before page overhead
+func (report GCReport) UnusedBytes() int64 {
+	var total int64
+	for _, table := range report.Tables {
+		total += table.Bytes
+	}
+	return total
+}
+
+func (report GCReport) UnusedRows() int {
+	total := 0
+	for _, table := range report.Tables {
+		total += table.Unused
+	}
+	return total
+}
+
+func fileSizes(paths ...string) int64 {
+	var total int64
+	for _, path := range paths {
+		if stat, err := os.Stat(path); err == nil && path != "" {
+			total += stat.Size()
+		}
+	}
+	return total
+}
+
+// CollectGarbage finds lookup rows, ROM mappings and ImageBlob rows no
+// Game references, in the MGDB and its media pack. With apply they are deleted and both files
+// vacuumed, otherwise nothing is written.
+func CollectGarbage(path string, apply bool) (GCReport, error) {
+	report := GCReport{Path: path, Applied: apply}
+	if _, err := os.Stat(path); err != nil {
+		return report, err
+	}
+	if _, err := os.Stat(MediaPath(path)); err == nil {
+		report.MediaPath = MediaPath(path)
+	}
+	report.SizeBefore = fileSizes(report.Path, report.MediaPath)
+
+	mode := "ro"
+	if apply {
+		mode = "rw"
+	}
+	// Plain driver, the media pack is attached as its own schema
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%v?mode=%v", path, mode))
+	if err != nil {
+		return report, err
+	}
+	defer db.Close()
+	// attach is per connection
+	db.SetMaxOpenConns(1)
+	if report.MediaPath != "" {
+		if _, err := db.Exec("attach database ? as media", fmt.Sprintf("file:%v?mode=%v", report.MediaPath, mode)); err != nil {
+			return report, err
+		}
+	}
+
+	romQueries := gcRomQueries
+	if merged, err := HasColumn(db, "RomCrc", "CollectionID"); err != nil {
+		return report, err
+	} else if merged {
+		romQueries = gcMergedRomQueries
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	type gcTarget struct {
+		table string
+		from  string
+		where string
+		blobs bool
+	}
+	targets := []gcTarget{}
+	for _, query := range append(append([]gcQuery{}, gcQueries...), romQueries...) {
+		targets = append(targets, gcTarget{query.table, "main." + query.table, query.where, false})
+	}
+	targets = append(targets, gcTarget{"ImageBlob", "main.ImageBlob", gcBlobWhere, true})
+	if report.MediaPath != "" {
+		targets = append(targets, gcTarget{"media.ImageBlob", "media.ImageBlob", gcBlobWhere, true})
+	}
+
+	for _, target := range targets {
+		table := GCTable{Table: target.table}
+		bytes := "0"
+		if target.blobs {
+			bytes = "coalesce(sum(length(Bytes)), 0)"
+		}
+		err := tx.QueryRow(fmt.Sprintf(
+			"select count(*), %v from %v where %v", bytes, target.from, target.where,
+		)).Scan(&table.Unused, &table.Bytes)
+		if err != nil {
+			return report, fmt.Errorf("%v: %w", target.table, err)
+		}
+		if err := tx.QueryRow(fmt.Sprintf("select count(*) from %v", target.from)).Scan(&table.Rows); err != nil {
+			return report, err
+		}
+		report.Tables = append(report.Tables, table)
+		if apply && table.Unused > 0 {
+			if _, err := tx.Exec(fmt.Sprintf("delete from %v where %v", target.from, target.where)); err != nil {
+				return report, fmt.Errorf("%v: %w", target.table, err)
+			}
+		}
+	}
+	if !apply {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+
+	if _, err := db.Exec("vacuum main"); err != nil {
+		return report, err
+	}
+	if report.MediaPath != "" {
+		if _, err := db.Exec("vacuum media"); err != nil {
+			return report, err
+		}
+	}
+	db.Close()
+	report.SizeAfter = fileSizes(report.Path, report.MediaPath)
+	return report, nil
+}
@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LocalTables hold state written on the MiSTer by indexmgdb, not part of a release
+var LocalTables = map[string]bool{"IndexedRom": true}
+
+// LocalColumns are per table columns derived from LocalTables
+var LocalColumns = map[string]map[string]bool{"Game": {"IsIndexed": true}}
+
+type Column struct {
+	Name string
+	PK   int // position in the primary key, 0 when not part of it
+}
+
+// GetTableNames lists the tables of an attached schema ("main" for the MGDB itself)
+func GetTableNames(db *sql.DB, schema string) ([]string, error) {
+	tables := []string{}
+	rows, err := db.Query(fmt.Sprintf(
+		"select name from %v.sqlite_master where type = 'table' and name not like 'sqlite_%%' order by name", QuoteIdent(schema),
+	))
+	if err != nil {
+		return tables, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		name := ""
+		if err := rows.Scan(&name); err != nil {
+			return tables, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// GetTableColumns lists a table's columns in schema order
+func GetTableColumns(db *sql.DB, schema string, table string) ([]Column, error) {
+	columns := []Column{}
+	rows, err := db.Query("select name, pk from pragma_table_info(?, ?) order by cid", table, schema)
+	if err != nil {
+		return columns, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		column := Column{}
+		if err := rows.Scan(&column.Name, &column.PK); err != nil {
+			return columns, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// PrimaryKey returns the primary key column names in key order
+func PrimaryKey(columns []Column) []string {
+	pk := []Column{}
+	for _, column := range columns {
+		if column.PK > 0 {
+			pk = append(pk, column)
+		}
+	}
+	sort.Slice(pk, func(i, j int) bool {
+		return pk[i].PK < pk[j].PK
+	})
+	names := []string{}
+	for _, column := range pk {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+func QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteIdents(names []string) string {
+	quoted := []string{}
+	for _, name := range names {
+		quoted = append(quoted, QuoteIdent(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// ContentHash is a sha256 over every release table's column names and rows,
+// ordered by primary key. Unlike the file hash it does not depend on page
+// layout, so a patched MGDB hashes the same as a fresh build of the same data.
+// LocalTables and LocalColumns are skipped so indexed MGDBs still match.
+// Only main is read, a split MGDB hashes without the images in its pack.
+func ContentHash(db *sql.DB) (string, error) {
+	tables, err := GetTableNames(db, "main")
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	for _, table := range tables {
+		if LocalTables[table] {
+			continue
+		}
+		columns, err := GetTableColumns(db, "main", table)
+		if err != nil {
+			return "", err
+		}
+		names := []string{}
+		for _, column := range columns {
+			if !LocalColumns[table][column.Name] {
+				names = append(names, column.Name)
+			}
+		}
+		order := PrimaryKey(columns)
+		if len(order) == 0 {
+			order = names
+		}
+		writeHashValue(sum, table)
+		writeHashValue(sum, strings.Join(names, ","))
+		if err := hashRows(db, sum, fmt.Sprintf(
+			"select %v from main.%v order by %v", quoteIdents(names), QuoteIdent(table), quoteIdents(order),
+		), len(names)); err != nil {
+			return "", fmt.Errorf("unable to hash %v: %w", table, err)
+		}
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func hashRows(db *sql.DB, sum hash.Hash, query string, columnCount int) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	values := make([]any, columnCount)
+	pointers := make([]any, columnCount)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		for _, value := range values {
+			writeHashValue(sum, value)
+		}
+	}
+	return rows.Err()
+}
+
+// Type tagged and length prefixed, so adjacent values cannot run together
+func writeHashValue(sum hash.Hash, value any) {
+	var data []byte
+	tag := byte('n')
+	switch v := value.(type) {
+	case int64:
+		tag = 'i'
+		data = uint64Bytes(uint64(v))
+	case float64:
+		tag = 'f'
+		data = uint64Bytes(math.Float64bits(v))
+	case string:
+		tag = 't'
+		data = []byte(v)
+	case []byte:
+		tag = 'b'
+		data = v
+	case time.Time:
+		tag = 't'
+		data = []byte(v.Format(time.RFC3339Nano))
+	}
+	sum.Write([]byte{tag})
+	sum.Write(uint64Bytes(uint64(len(data))))
+	sum.Write(data)
+}
+
+func uint64Bytes(value uint64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, value)
+	return data
+}
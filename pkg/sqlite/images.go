@@ -0,0 +1,191 @@
+package sqlite
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image/png"
+	"path/filepath"
+	"sync"
+)
+
+// Game image columns holding ImageBlob hashes
+const (
+	ScreenshotColumn  = "ScreenshotHash"
+	TitleScreenColumn = "TitleScreenHash"
+)
+
+// ImageRef is an image file for one Game column, relative to the core folder
+type ImageRef struct {
+	GameID int
+	Column string
+	Path   string
+}
+
+type ImageOptions struct {
+	Workers    int  // concurrent file loads, at least 1
+	Recompress bool // re-encode PNGs at best compression when it saves bytes
+}
+
+type ImageStats struct {
+	Images     int // Game columns set
+	Missing    int // files that could not be read
+	Blobs      int // distinct ImageBlob rows inserted
+	BlobBytes  int64
+	Recompress int // PNGs replaced by a smaller encoding
+	SavedBytes int64
+}
+
+type loadedImage struct {
+	ref        ImageRef
+	hash       string
+	blob       []byte
+	recompress int64 // bytes saved, 0 when untouched
+	err        error // a panic while loading, failing the build
+}
+
+// loadImage reads, optionally recompresses and hashes one image. Workers
+// run outside the build's recover, so a panic is returned as an error.
+func loadImage(ref ImageRef, basePath string, options ImageOptions) (loaded loadedImage) {
+	loaded.ref = ref
+	defer func() {
+		if r := recover(); r != nil {
+			loaded.blob = nil
+			loaded.err = fmt.Errorf("%v: %v", ref.Path, r)
+		}
+	}()
+	loaded.blob = safeLoadFileBytes(filepath.Join(basePath, ref.Path))
+	if loaded.blob != nil {
+		if options.Recompress {
+			original := len(loaded.blob)
+			loaded.blob = recompressPNG(loaded.blob)
+			loaded.recompress = int64(original - len(loaded.blob))
+		}
+		loaded.hash = GetMD5Hash(loaded.blob)
+	}
+	return loaded
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// recompressPNG returns the smaller of the original and a best compression
+// encoding. Anything that is not a decodable PNG is returned as is.
+func recompressPNG(blob []byte) []byte {
+	if !bytes.HasPrefix(blob, pngSignature) {
+		return blob
+	}
+	img, err := png.Decode(bytes.NewReader(blob))
+	if err != nil {
+		return blob
+	}
+	buf := bytes.Buffer{}
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil || buf.Len() >= len(blob) {
+		return blob
+	}
+	return buf.Bytes()
+}
+
+// InsertImages loads, hashes and optionally re-encodes image files on a
+// bounded pool of workers. A single writer, the caller's goroutine, owns
+// the connection: it inserts each distinct blob once and sets the Game
+// column, all in one transaction. At most two results per worker are held
+// in memory waiting for the writer.
+func InsertImages(db *sql.DB, refs []ImageRef, basePath string, options ImageOptions) (ImageStats, error) {
+	stats := ImageStats{}
+	workers := options.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan ImageRef)
+	results := make(chan loadedImage, workers*2)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(jobs)
+		for _, ref := range refs {
+			if ref.GameID == 0 || ref.Path == "" {
+				continue
+			}
+			select {
+			case jobs <- ref:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range jobs {
+				loaded := loadImage(ref, basePath, options)
+				select {
+				case results <- loaded:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback()
+	insertBlob, err := tx.Prepare("insert or ignore into ImageBlob(Hash, Bytes) values (?, ?)")
+	if err != nil {
+		return stats, err
+	}
+	defer insertBlob.Close()
+	updates := make(map[string]*sql.Stmt)
+	for _, column := range []string{ScreenshotColumn, TitleScreenColumn} {
+		stmt, err := tx.Prepare("update Game set " + column + " = ? where GameID = ?")
+		if err != nil {
+			return stats, err
+		}
+		defer stmt.Close()
+		updates[column] = stmt
+	}
+
+	inserted := make(map[string]bool) // [hash]exists
+	for loaded := range results {
+		if loaded.err != nil {
+			return stats, loaded.err
+		}
+		if loaded.blob == nil {
+			stats.Missing++
+			continue
+		}
+		update, ok := updates[loaded.ref.Column]
+		if !ok {
+			return stats, fmt.Errorf("unknown image column %v", loaded.ref.Column)
+		}
+		if !inserted[loaded.hash] {
+			if _, err := insertBlob.Exec(loaded.hash, loaded.blob); err != nil {
+				return stats, fmt.Errorf("%v: %w", loaded.ref.Path, err)
+			}
+			inserted[loaded.hash] = true
+			stats.Blobs++
+			stats.BlobBytes += int64(len(loaded.blob))
+			if loaded.recompress > 0 {
+				stats.Recompress++
+				stats.SavedBytes += loaded.recompress
+			}
+		}
+		if _, err := update.Exec(loaded.hash, loaded.ref.GameID); err != nil {
+			return stats, fmt.Errorf("GameID %v: %w", loaded.ref.GameID, err)
+		}
+		stats.Images++
+	}
+	return stats, tx.Commit()
+}
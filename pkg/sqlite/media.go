@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// MediaSuffix names the media pack split from an MGDB, kept next to it
+const MediaSuffix = ".media.mgdb"
+
+// mgdbDriver attaches the media pack, when present, on every connection
+// OpenMGDB makes. A temp ImageBlob view shadows the empty main table, so
+// image queries resolve from the pack without knowing about it.
+const mgdbDriver = "sqlite3_mgdb"
+
+func init() {
+	sql.Register(mgdbDriver, &sqlite3.SQLiteDriver{ConnectHook: attachMedia})
+}
+
+// MediaPath is the media pack path for an MGDB
+func MediaPath(path string) string {
+	return strings.TrimSuffix(path, ".mgdb") + MediaSuffix
+}
+
+func IsMediaPath(path string) bool {
+	return strings.HasSuffix(path, MediaSuffix)
+}
+
+func attachMedia(conn *sqlite3.SQLiteConn) error {
+	path := conn.GetFilename("main")
+	if path == "" || IsMediaPath(path) {
+		return nil
+	}
+	mediaPath := MediaPath(path)
+	if _, err := os.Stat(mediaPath); err != nil {
+		return nil
+	}
+	if _, err := conn.Exec("attach database ? as media", []driver.Value{fmt.Sprintf("file:%v?mode=ro", mediaPath)}); err != nil {
+		return fmt.Errorf("unable to attach %v: %w", mediaPath, err)
+	}
+	_, err := conn.Exec(
+		"create temp view ImageBlob as select Hash, Bytes from main.ImageBlob "+
+			"union all select Hash, Bytes from media.ImageBlob where Hash not in (select Hash from main.ImageBlob)",
+		nil,
+	)
+	return err
+}
+
+// HasMedia reports whether an MGDB connection resolves images from a media pack
+func HasMedia(db *sql.DB) (bool, error) {
+	count := 0
+	err := db.QueryRow("select count(*) from pragma_database_list where name = 'media'").Scan(&count)
+	return count > 0, err
+}
+
+type SplitSummary struct {
+	MetaPath  string
+	MediaPath string
+	Blobs     int
+	BlobBytes int64
+}
+
+// SplitMGDB writes a copy of the MGDB at path without images to metaPath,
+// and its ImageBlob rows to the media pack at MediaPath(metaPath).
+// The pack also carries MGDBInfo so it can be matched to its MGDB.
+func SplitMGDB(path string, metaPath string) (SplitSummary, error) {
+	summary := SplitSummary{MetaPath: metaPath, MediaPath: MediaPath(metaPath)}
+	if _, err := os.Stat(path); err != nil {
+		return summary, err
+	}
+	for _, outPath := range []string{summary.MetaPath, summary.MediaPath} {
+		if err := os.Remove(outPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return summary, err
+		}
+	}
+
+	// Plain driver, a pack next to the source must not be read back in
+	src, err := sql.Open("sqlite3", fmt.Sprintf("file:%v?mode=ro", path))
+	if err != nil {
+		return summary, err
+	}
+	defer src.Close()
+	if err := src.QueryRow("select count(*), coalesce(sum(length(Bytes)), 0) from ImageBlob").Scan(
+		&summary.Blobs, &summary.BlobBytes,
+	); err != nil {
+		return summary, err
+	}
+	if _, err := src.Exec("vacuum into ?", summary.MetaPath); err != nil {
+		return summary, err
+	}
+
+	meta, err := sql.Open("sqlite3", summary.MetaPath)
+	if err != nil {
+		return summary, err
+	}
+	defer meta.Close()
+	// attach is per connection
+	meta.SetMaxOpenConns(1)
+	if _, err := meta.Exec("attach database ? as media", summary.MediaPath); err != nil {
+		return summary, err
+	}
+	if _, err := meta.Exec(`
+	create table media.MGDBInfo as select * from main.MGDBInfo;
+	create table media.ImageBlob (
+		Hash text primary key not null,
+		Bytes blob not null
+	);
+	insert into media.ImageBlob (Hash, Bytes) select Hash, Bytes from main.ImageBlob;
+	delete from main.ImageBlob;`); err != nil {
+		return summary, err
+	}
+	if _, err := meta.Exec("detach database media"); err != nil {
+		return summary, err
+	}
+	_, err = meta.Exec("vacuum")
+	return summary, err
+}
@@ -0,0 +1,212 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+)
+
+// GameRow is a Game joined with its genre, developer and publisher names
+type GameRow struct {
+	mgdb.Game
+	Genre     string
+	Developer string
+	Publisher string
+}
+
+// GameFilter narrows QueryGames. Genre, Developer and Publisher match an
+// ID when numeric, otherwise a case-insensitive name.
+type GameFilter struct {
+	Genre     string
+	Developer string
+	Publisher string
+	Indexed   *bool
+	Search    string // substring of the game name
+	Limit     int    // 0 is unlimited
+	Offset    int
+}
+
+const gameRowSelect = "select g.GameID, g.Name, g.IsIndexed, g.GenreID, g.Rating, g.ReleaseDate, " +
+	"g.DeveloperID, g.PublisherID, g.Players, g.Description, g.ExternalID, g.ScreenshotHash, g.TitleScreenHash, " +
+	"coalesce(ge.Name, ''), coalesce(d.Name, ''), coalesce(p.Name, '') " +
+	"from Game g " +
+	"left join Genre ge on ge.GenreID = g.GenreID " +
+	"left join Developer d on d.DeveloperID = g.DeveloperID " +
+	"left join Publisher p on p.PublisherID = g.PublisherID "
+
+func scanGameRow(scanner interface{ Scan(...any) error }) (GameRow, error) {
+	row := GameRow{}
+	var screenshotHash, titleScreenHash sql.NullString
+	err := scanner.Scan(
+		&row.GameID,
+		&row.Name,
+		&row.IsIndexed,
+		&row.GenreID,
+		&row.Rating,
+		&row.ReleaseDate,
+		&row.DeveloperID,
+		&row.PublisherID,
+		&row.Players,
+		&row.Description,
+		&row.ExternalID,
+		&screenshotHash,
+		&titleScreenHash,
+		&row.Genre,
+		&row.Developer,
+		&row.Publisher,
+	)
+	row.ScreenshotHash = screenshotHash.String
+	row.TitleScreenHash = titleScreenHash.String
+	return row, err
+}
+
+// Escapes LIKE wildcards, used with escape '\'
+func likeContains(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+	return "%" + value + "%"
+}
+
+func (filter GameFilter) where() (string, []any) {
+	clauses := []string{"g.GameID != 0"}
+	args := []any{}
+	byIDOrName := func(value string, idColumn string, nameColumn string) {
+		if value == "" {
+			return
+		}
+		if id, err := strconv.Atoi(value); err == nil {
+			clauses = append(clauses, idColumn+" = ?")
+			args = append(args, id)
+		} else {
+			clauses = append(clauses, nameColumn+" = ? collate nocase")
+			args = append(args, value)
+		}
+	}
+	byIDOrName(filter.Genre, "g.GenreID", "ge.Name")
+	byIDOrName(filter.Developer, "g.DeveloperID", "d.Name")
+	byIDOrName(filter.Publisher, "g.PublisherID", "p.Name")
+	if filter.Indexed != nil {
+		clauses = append(clauses, "g.IsIndexed = ?")
+		if *filter.Indexed {
+			args = append(args, 1)
+		} else {
+			args = append(args, 0)
+		}
+	}
+	if filter.Search != "" {
+		clauses = append(clauses, `g.Name like ? escape '\'`)
+		args = append(args, likeContains(filter.Search))
+	}
+	return "where " + strings.Join(clauses, " and ") + " ", args
+}
+
+// QueryGames returns a page of games by name and the total matching the filter.
+// The ~Unknown loose ROM game is never listed.
+func QueryGames(db *sql.DB, filter GameFilter) ([]GameRow, int, error) {
+	games := []GameRow{}
+	where, args := filter.where()
+
+	total := 0
+	err := db.QueryRow(
+		"select count(*) from Game g "+
+			"left join Genre ge on ge.GenreID = g.GenreID "+
+			"left join Developer d on d.DeveloperID = g.DeveloperID "+
+			"left join Publisher p on p.PublisherID = g.PublisherID "+where,
+		args...,
+	).Scan(&total)
+	if err != nil {
+		return games, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := db.Query(
+		gameRowSelect+where+"order by g.Name collate nocase, g.GameID limit ? offset ?",
+		append(args, limit, filter.Offset)...,
+	)
+	if err != nil {
+		return games, total, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row, err := scanGameRow(rows)
+		if err != nil {
+			return games, total, err
+		}
+		games = append(games, row)
+	}
+	return games, total, rows.Err()
+}
+
+// GetGameRow returns sql.ErrNoRows for unknown games
+func GetGameRow(db *sql.DB, gameID int) (GameRow, error) {
+	return scanGameRow(db.QueryRow(gameRowSelect+"where g.GameID = ?", gameID))
+}
+
+func GetGameIndexedRoms(db *sql.DB, gameID int) ([]mgdb.IndexedRom, error) {
+	roms := []mgdb.IndexedRom{}
+	rows, err := db.Query(
+		"select Path, FileName, FileExt, GameID, SupportedSystemIds from IndexedRom where GameID = ? order by Path",
+		gameID,
+	)
+	if err != nil {
+		return roms, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rom := mgdb.IndexedRom{}
+		if err := rows.Scan(&rom.Path, &rom.FileName, &rom.FileExt, &rom.GameID, &rom.SupportedSystemIds); err != nil {
+			return roms, err
+		}
+		roms = append(roms, rom)
+	}
+	return roms, rows.Err()
+}
+
+// GetGameRomTags lists the RDB ROMs known for a game, through its slugs and
+// their CRCs. MGDBs built before RomTag existed return none. In merged MGDBs
+// the joins stay within the game's collection.
+func GetGameRomTags(db *sql.DB, gameID int) ([]mgdb.RomTag, error) {
+	tags := []mgdb.RomTag{}
+	if ok, err := HasColumn(db, "RomTag", "CRC32"); err != nil || !ok {
+		return tags, err
+	}
+	merged, err := IsMerged(db)
+	if err != nil {
+		return tags, err
+	}
+	crcJoin := "join RomCrc c on c.CRC32 = t.CRC32 "
+	slugJoin := "join SlugRom s on s.Slug = c.Slug "
+	if merged {
+		crcJoin = "join RomCrc c on c.CRC32 = t.CRC32 and c.CollectionID = t.CollectionID "
+		slugJoin = "join SlugRom s on s.Slug = c.Slug and s.CollectionID = c.CollectionID "
+	}
+	rows, err := db.Query(
+		"select t.RomName, t.CRC32, t.Title, t.Regions, t.Languages, t.Revision, t.Version, "+
+			"t.IsBeta, t.IsProto, t.IsDemo, t.IsUnlicensed, t.IsPirate, t.IsHack, t.Translation, "+
+			"t.IsVerified, t.IsBadDump, t.IsAlternate, t.Extra "+
+			"from RomTag t "+crcJoin+slugJoin+
+			"where s.GameID = ? order by t.RomName",
+		gameID,
+	)
+	if err != nil {
+		return tags, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		tag := mgdb.RomTag{}
+		err := rows.Scan(
+			&tag.RomName, &tag.CRC32, &tag.Title, &tag.Regions, &tag.Languages, &tag.Revision, &tag.Version,
+			&tag.IsBeta, &tag.IsProto, &tag.IsDemo, &tag.IsUnlicensed, &tag.IsPirate, &tag.IsHack, &tag.Translation,
+			&tag.IsVerified, &tag.IsBadDump, &tag.IsAlternate, &tag.Extra,
+		)
+		if err != nil {
+			return tags, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
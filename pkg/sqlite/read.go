@@ -0,0 +1,328 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
+)
+
+// FindMGDBs expands directories to the .mgdb files they contain, media
+// packs are skipped as they are opened with their MGDB
+func FindMGDBs(args []string) ([]string, error) {
+	paths := []string{}
+	for _, arg := range args {
+		stat, err := os.Stat(arg)
+		if err != nil {
+			return paths, err
+		}
+		if !stat.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.mgdb"))
+		if err != nil {
+			return paths, err
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if !IsMediaPath(match) {
+				paths = append(paths, match)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// OpenMGDB opens an existing MGDB read-only, with images from its media
+// pack when one was split off. Without the pack images are simply missing.
+func OpenMGDB(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(mgdbDriver, fmt.Sprintf("file:%v?mode=ro", path))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// OpenMGDBFile opens an MGDB, or a media pack, read-only as the file alone.
+// Hashing and patching use it so a media pack next to the file can't
+// change their results.
+func OpenMGDBFile(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%v?mode=ro", path))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// HasColumn checks a table for a column, for MGDBs built before it was added
+func HasColumn(db *sql.DB, table string, column string) (bool, error) {
+	count := 0
+	err := db.QueryRow("select count(*) from pragma_table_info(?) where name = ?", table, column).Scan(&count)
+	return count > 0, err
+}
+
+func GetMGDBInfo(db *sql.DB) (mgdb.MGDBInfo, error) {
+	info := mgdb.MGDBInfo{}
+	revisionColumns := "'', ''"
+	if ok, err := HasColumn(db, "MGDBInfo", "RdbRevision"); err != nil {
+		return info, err
+	} else if ok {
+		revisionColumns = "RdbRevision, RdbSHA256"
+	}
+	err := db.QueryRow(
+		"select CollectionName, GamesFolder, SupportedSystemIds, BuildDate, MGDBVersion, Description, "+
+			revisionColumns+" from MGDBInfo",
+	).Scan(
+		&info.CollectionName,
+		&info.GamesFolder,
+		&info.SupportedSystemIds,
+		&info.BuildDate,
+		&info.MGDBVersion,
+		&info.Description,
+		&info.RdbRevision,
+		&info.RdbSHA256,
+	)
+	return info, err
+}
+
+func GetIndexedRoms(db *sql.DB) ([]mgdb.IndexedRom, error) {
+	roms := []mgdb.IndexedRom{}
+	rows, err := db.Query(
+		"select Path, FileName, FileExt, GameID, SupportedSystemIds from IndexedRom order by Path",
+	)
+	if err != nil {
+		return roms, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rom := mgdb.IndexedRom{}
+		if err := rows.Scan(&rom.Path, &rom.FileName, &rom.FileExt, &rom.GameID, &rom.SupportedSystemIds); err != nil {
+			return roms, err
+		}
+		roms = append(roms, rom)
+	}
+	return roms, rows.Err()
+}
+
+func GetGames(db *sql.DB) ([]mgdb.Game, error) {
+	games := []mgdb.Game{}
+	rows, err := db.Query(
+		"select GameID, Name, IsIndexed, GenreID, Rating, ReleaseDate, DeveloperID, PublisherID, " +
+			"Players, Description, ExternalID, ScreenshotHash, TitleScreenHash from Game order by GameID",
+	)
+	if err != nil {
+		return games, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		game := mgdb.Game{}
+		var screenshotHash, titleScreenHash sql.NullString
+		err := rows.Scan(
+			&game.GameID,
+			&game.Name,
+			&game.IsIndexed,
+			&game.GenreID,
+			&game.Rating,
+			&game.ReleaseDate,
+			&game.DeveloperID,
+			&game.PublisherID,
+			&game.Players,
+			&game.Description,
+			&game.ExternalID,
+			&screenshotHash,
+			&titleScreenHash,
+		)
+		if err != nil {
+			return games, err
+		}
+		game.ScreenshotHash = screenshotHash.String
+		game.TitleScreenHash = titleScreenHash.String
+		games = append(games, game)
+	}
+	return games, rows.Err()
+}
+
+func GetSlugRoms(db *sql.DB) ([]mgdb.SlugRom, error) {
+	return querySlugRoms(db, "select Slug, GameID, SupportedSystemIds from SlugRom order by Slug")
+}
+
+// GetCollectionSlugRoms lists the SlugRom rows of one collection in a merged MGDB
+func GetCollectionSlugRoms(db *sql.DB, collectionID int) ([]mgdb.SlugRom, error) {
+	return querySlugRoms(db,
+		"select Slug, GameID, SupportedSystemIds from SlugRom where CollectionID = ? order by Slug", collectionID,
+	)
+}
+
+func querySlugRoms(db *sql.DB, query string, args ...any) ([]mgdb.SlugRom, error) {
+	slugRoms := []mgdb.SlugRom{}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return slugRoms, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		slugRom := mgdb.SlugRom{}
+		if err := rows.Scan(&slugRom.Slug, &slugRom.GameID, &slugRom.SupportedSystemIds); err != nil {
+			return slugRoms, err
+		}
+		slugRoms = append(slugRoms, slugRom)
+	}
+	return slugRoms, rows.Err()
+}
+
+func GetRomCrcs(db *sql.DB) ([]mgdb.RomCrc, error) {
+	return queryRomCrcs(db, "select CRC32, Slug from RomCrc order by CRC32")
+}
+
+// GetCollectionRomCrcs lists the RomCrc rows of one collection in a merged MGDB
+func GetCollectionRomCrcs(db *sql.DB, collectionID int) ([]mgdb.RomCrc, error) {
+	return queryRomCrcs(db, "select CRC32, Slug from RomCrc where CollectionID = ? order by CRC32", collectionID)
+}
+
+func queryRomCrcs(db *sql.DB, query string, args ...any) ([]mgdb.RomCrc, error) {
+	romCrcs := []mgdb.RomCrc{}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return romCrcs, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		romCrc := mgdb.RomCrc{}
+		if err := rows.Scan(&romCrc.CRC32, &romCrc.Slug); err != nil {
+			return romCrcs, err
+		}
+		romCrcs = append(romCrcs, romCrc)
+	}
+	return romCrcs, rows.Err()
+}
+
+// MergedCollection is a source MGDB listed in a merged MGDB's Collection table
+type MergedCollection struct {
+	CollectionID       int
+	CollectionName     string
+	GamesFolder        string
+	SupportedSystemIds string
+}
+
+// IsMerged reports whether an MGDB was combined by mergemgdb. Slugs and CRCs
+// are only unique per system, so its SlugRom, RomCrc and RomTag rows must be
+// read per CollectionID.
+func IsMerged(db *sql.DB) (bool, error) {
+	return HasTable(db, "Collection")
+}
+
+func GetMergedCollections(db *sql.DB) ([]MergedCollection, error) {
+	collections := []MergedCollection{}
+	rows, err := db.Query(
+		"select CollectionID, CollectionName, GamesFolder, SupportedSystemIds from Collection order by CollectionID",
+	)
+	if err != nil {
+		return collections, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		collection := MergedCollection{}
+		err := rows.Scan(&collection.CollectionID, &collection.CollectionName, &collection.GamesFolder, &collection.SupportedSystemIds)
+		if err != nil {
+			return collections, err
+		}
+		collections = append(collections, collection)
+	}
+	return collections, rows.Err()
+}
+
+// GetCollectionExternalIDs maps one collection's game ExternalIDs to GameIDs
+func GetCollectionExternalIDs(db *sql.DB, collectionID int) (map[string]int, error) {
+	externalIDs := make(map[string]int)
+	rows, err := db.Query(
+		"select ExternalID, GameID from Game where CollectionID = ? and ExternalID != ''", collectionID,
+	)
+	if err != nil {
+		return externalIDs, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		externalID, gameID := "", 0
+		if err := rows.Scan(&externalID, &gameID); err != nil {
+			return externalIDs, err
+		}
+		externalIDs[externalID] = gameID
+	}
+	return externalIDs, rows.Err()
+}
+
+func GetGenres(db *sql.DB) ([]mgdb.Genre, error) {
+	genres := []mgdb.Genre{}
+	rows, err := db.Query("select GenreID, Name from Genre order by GenreID")
+	if err != nil {
+		return genres, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		genre := mgdb.Genre{}
+		if err := rows.Scan(&genre.GenreID, &genre.Name); err != nil {
+			return genres, err
+		}
+		genres = append(genres, genre)
+	}
+	return genres, rows.Err()
+}
+
+func GetDevelopers(db *sql.DB) ([]mgdb.Developer, error) {
+	developers := []mgdb.Developer{}
+	rows, err := db.Query("select DeveloperID, Name from Developer order by DeveloperID")
+	if err != nil {
+		return developers, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		developer := mgdb.Developer{}
+		if err := rows.Scan(&developer.DeveloperID, &developer.Name); err != nil {
+			return developers, err
+		}
+		developers = append(developers, developer)
+	}
+	return developers, rows.Err()
+}
+
+func GetPublishers(db *sql.DB) ([]mgdb.Publisher, error) {
+	publishers := []mgdb.Publisher{}
+	rows, err := db.Query("select PublisherID, Name from Publisher order by PublisherID")
+	if err != nil {
+		return publishers, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		publisher := mgdb.Publisher{}
+		if err := rows.Scan(&publisher.PublisherID, &publisher.Name); err != nil {
+			return publishers, err
+		}
+		publishers = append(publishers, publisher)
+	}
+	return publishers, rows.Err()
+}
+
+// GetImageBlob returns sql.ErrNoRows for unknown hashes
+func GetImageBlob(db *sql.DB, hash string) (mgdb.ImageBlob, error) {
+	blob := mgdb.ImageBlob{Hash: hash}
+	err := db.QueryRow("select Bytes from ImageBlob where Hash = ?", hash).Scan(&blob.Bytes)
+	return blob, err
+}
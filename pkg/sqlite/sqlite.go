@@ -5,9 +5,7 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
-	"io"
 	"os"
-	"path/filepath"
 
 	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mgdb"
 	_ "github.com/mattn/go-sqlite3"
@@ -27,7 +25,9 @@ func allocDB(path string) (*sql.DB, error) {
 		SupportedSystemIds text not null,
 		BuildDate text not null,
 		MGDBVersion text not null,
-		Description text not null
+		Description text not null,
+		RdbRevision text not null default '',
+		RdbSHA256 text not null default ''
 	);`
 	_, err = db.Exec(sqlStmt)
 	if err != nil {
@@ -85,6 +85,36 @@ func allocDB(path string) (*sql.DB, error) {
 		return db, err
 	}
 
+	// Filename tags, keyed by CRC32 with RomName for CRC-less sets
+	sqlStmt = `
+	drop table if exists RomTag;
+	create table RomTag (
+		RomName text not null,
+		CRC32 text not null,
+		Title text not null,
+		Regions text not null,
+		Languages text not null,
+		Revision text not null,
+		Version text not null,
+		IsBeta integer not null,
+		IsProto integer not null,
+		IsDemo integer not null,
+		IsUnlicensed integer not null,
+		IsPirate integer not null,
+		IsHack integer not null,
+		Translation text not null,
+		IsVerified integer not null,
+		IsBadDump integer not null,
+		IsAlternate integer not null,
+		Extra text not null,
+		primary key (CRC32, RomName)
+	);
+	CREATE INDEX romtag_romname_idx ON RomTag (RomName);`
+	_, err = db.Exec(sqlStmt)
+	if err != nil {
+		return db, err
+	}
+
 	sqlStmt = `
 	drop table if exists IndexedRom;
 	create table IndexedRom (
@@ -166,6 +196,14 @@ func CreateMGDB(path string) (*sql.DB, error) {
 	return db, nil
 }
 
+// OpenMGDBForUpdate opens an existing MGDB for writes without reallocating tables
+func OpenMGDBForUpdate(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return sql.Open("sqlite3", path)
+}
+
 func Vacuum(db *sql.DB) {
 	sqlStmt := `VACUUM;`
 	_, err := db.Exec(sqlStmt)
@@ -178,8 +216,9 @@ func Vacuum(db *sql.DB) {
 func InsertMGDBInfo(db *sql.DB, info mgdb.MGDBInfo) {
 	stmt, err := db.Prepare(
 		"insert into MGDBInfo(" +
-			"CollectionName, GamesFolder, SupportedSystemIds, BuildDate, MGDBVersion, Description" +
-			") values (?, ?, ?, ?, ?, ?)",
+			"CollectionName, GamesFolder, SupportedSystemIds, BuildDate, MGDBVersion, Description, " +
+			"RdbRevision, RdbSHA256" +
+			") values (?, ?, ?, ?, ?, ?, ?, ?)",
 	)
 	if err != nil {
 		panic("InsertMGDBInfo Prepare")
@@ -191,6 +230,8 @@ func InsertMGDBInfo(db *sql.DB, info mgdb.MGDBInfo) {
 		info.BuildDate,
 		info.MGDBVersion,
 		info.Description,
+		info.RdbRevision,
+		info.RdbSHA256,
 	)
 	if err != nil {
 		panic("InsertMGDBInfo Exec")
@@ -199,7 +240,6 @@ func InsertMGDBInfo(db *sql.DB, info mgdb.MGDBInfo) {
 
 func BulkInsertGames(db *sql.DB, games []mgdb.Game) {
 	for _, game := range games {
-		fmt.Println("adding game", game.Name)
 		stmt, err := db.Prepare(
 			"insert into Game(" +
 				"GameID, Name, IsIndexed, GenreID, Rating, ReleaseDate, " +
@@ -233,7 +273,6 @@ func BulkInsertGames(db *sql.DB, games []mgdb.Game) {
 
 func BulkInsertGenres(db *sql.DB, genres []mgdb.Genre) {
 	for _, genre := range genres {
-		fmt.Println("adding genre", genre.Name)
 		stmt, err := db.Prepare(
 			"insert into Genre(" +
 				"GenreID, Name" +
@@ -256,7 +295,6 @@ func BulkInsertGenres(db *sql.DB, genres []mgdb.Genre) {
 
 func BulkInsertDevelopers(db *sql.DB, developers []mgdb.Developer) {
 	for _, developer := range developers {
-		fmt.Println("adding Developer", developer.Name)
 		stmt, err := db.Prepare(
 			"insert into Developer(" +
 				"DeveloperID, Name" +
@@ -279,7 +317,6 @@ func BulkInsertDevelopers(db *sql.DB, developers []mgdb.Developer) {
 
 func BulkInsertPublishers(db *sql.DB, publishers []mgdb.Publisher) {
 	for _, publisher := range publishers {
-		fmt.Println("adding Publisher", publisher.Name)
 		stmt, err := db.Prepare(
 			"insert into Publisher(" +
 				"PublisherID, Name" +
@@ -302,7 +339,6 @@ func BulkInsertPublishers(db *sql.DB, publishers []mgdb.Publisher) {
 
 func BulkInsertSlugRoms(db *sql.DB, slugRomMap map[string]mgdb.SlugRom) {
 	for _, rom := range slugRomMap {
-		fmt.Println("adding SlugRom", rom.Slug)
 		stmt, err := db.Prepare(
 			"insert into SlugRom(" +
 				"Slug, GameID, SupportedSystemIds" +
@@ -326,7 +362,6 @@ func BulkInsertSlugRoms(db *sql.DB, slugRomMap map[string]mgdb.SlugRom) {
 
 func BulkInsertRomCrcs(db *sql.DB, romCrcs []mgdb.RomCrc) {
 	for _, rom := range romCrcs {
-		fmt.Println("adding RomCrc", rom.CRC32, rom.Slug)
 		stmt, err := db.Prepare(
 			"insert into RomCrc(" +
 				"CRC32, Slug" +
@@ -341,83 +376,90 @@ func BulkInsertRomCrcs(db *sql.DB, romCrcs []mgdb.RomCrc) {
 			rom.Slug,
 		)
 		if err != nil {
-			fmt.Printf("%+v\n", rom)
-			fmt.Println("Error BulkInsertRomCrcs Exec: Possible Dupe CRC, skipping")
+			fmt.Printf("Error BulkInsertRomCrcs Exec: Possible Dupe CRC, skipping %+v\n", rom)
 		}
 	}
 }
 
-func safeLoadFileBytes(path string) []byte {
-	var b []byte
-	imgFile, err := os.Open(path)
+// ReplaceIndexedRoms swaps the local file index and refreshes Game.IsIndexed
+func ReplaceIndexedRoms(db *sql.DB, roms []mgdb.IndexedRom) {
+	_, err := db.Exec("delete from IndexedRom; update Game set IsIndexed = 0;")
 	if err != nil {
-		fmt.Println("Unable to open file path", path)
-		return b
+		panic("ReplaceIndexedRoms Reset")
 	}
-	imageBytes, err := io.ReadAll(imgFile)
-	if err != nil && err != io.EOF {
-		fmt.Println("Unable to file file", path)
-		return b
-	}
-	return imageBytes
-}
-
-func BulkInsertImageMap(db *sql.DB, imgType string, imageMap map[int]string, md5Map map[string]bool, basePath string) {
-	for gameID, filePath := range imageMap {
-		if gameID == 0 || filePath == "" {
-			continue
-		}
-		fmt.Printf("adding image %v %v\n", imgType, filePath)
-		imgPath := filepath.Join(basePath, filePath)
-		blob := safeLoadFileBytes(imgPath)
-		if blob == nil {
-			continue
-		}
-
-		// Compare hash for dedupe
-		hash := GetMD5Hash(blob)
-		if _, ok := md5Map[hash]; !ok {
-			// ADD IMAGE RECORD
-			stmt, err := db.Prepare(
-				"insert into ImageBlob(Hash, Bytes) values (?, ?)",
-			)
-			if err != nil {
-				fmt.Printf("%v %v", hash, filePath)
-				panic("BulkInsertImageMap ImageBlob Prepare")
-			}
-			_, err = stmt.Exec(
-				hash,
-				blob,
-			)
-			if err != nil {
-				fmt.Printf("%+v\n", filePath)
-				panic("BulkInsertImageMap ImageBlob Exec")
-			}
-
-			md5Map[hash] = true
+	for _, rom := range roms {
+		fmt.Println("adding IndexedRom", rom.Path)
+		stmt, err := db.Prepare(
+			"insert into IndexedRom(" +
+				"Path, FileName, FileExt, GameID, SupportedSystemIds" +
+				") values (?, ?, ?, ?, ?)",
+		)
+		if err != nil {
+			fmt.Printf("%+v\n", rom)
+			panic("ReplaceIndexedRoms Prepare")
 		}
-
-		// Update game with hash by type
-
-		column := "ScreenshotHash"
-		if imgType == "TitleScreen" {
-			column = "TitleScreenHash"
+		_, err = stmt.Exec(
+			rom.Path,
+			rom.FileName,
+			rom.FileExt,
+			rom.GameID,
+			rom.SupportedSystemIds,
+		)
+		if err != nil {
+			fmt.Printf("%+v\n", rom)
+			fmt.Println("Error ReplaceIndexedRoms Exec: Possible Dupe Path, skipping")
 		}
+	}
+	_, err = db.Exec("update Game set IsIndexed = 1 where GameID in (select GameID from IndexedRom) and GameID != 0")
+	if err != nil {
+		panic("ReplaceIndexedRoms IsIndexed")
+	}
+}
 
+func BulkInsertRomTags(db *sql.DB, romTags []mgdb.RomTag) {
+	for _, tag := range romTags {
 		stmt, err := db.Prepare(
-			"update Game set " + column + " = ? where GameID = ?",
+			"insert into RomTag(" +
+				"RomName, CRC32, Title, Regions, Languages, Revision, Version, " +
+				"IsBeta, IsProto, IsDemo, IsUnlicensed, IsPirate, IsHack, " +
+				"Translation, IsVerified, IsBadDump, IsAlternate, Extra" +
+				") values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		)
 		if err != nil {
-			fmt.Printf("%v %v\n", gameID, hash)
-			panic("BulkInsertImageMap Game Update Prepare")
+			fmt.Printf("%+v\n", tag)
+			panic("BulkInsertRomTags Prepare")
 		}
 		_, err = stmt.Exec(
-			hash,
-			gameID,
+			tag.RomName,
+			tag.CRC32,
+			tag.Title,
+			tag.Regions,
+			tag.Languages,
+			tag.Revision,
+			tag.Version,
+			tag.IsBeta,
+			tag.IsProto,
+			tag.IsDemo,
+			tag.IsUnlicensed,
+			tag.IsPirate,
+			tag.IsHack,
+			tag.Translation,
+			tag.IsVerified,
+			tag.IsBadDump,
+			tag.IsAlternate,
+			tag.Extra,
 		)
 		if err != nil {
-			fmt.Printf("%v %v\n", gameID, hash)
-			panic("BulkInsertScreenshots Game Update Exec")
+			fmt.Printf("Error BulkInsertRomTags Exec: Possible Dupe ROM, skipping %+v\n", tag)
 		}
 	}
 }
+
+func safeLoadFileBytes(path string) []byte {
+	imageBytes, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("Unable to read file", path, err)
+		return nil
+	}
+	return imageBytes
+}
@@ -0,0 +1,128 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// MGDBTables in schema order, RomTag is missing from MGDBs built before it
+// was added and Collection only exists in merged MGDBs
+var MGDBTables = []string{
+	"MGDBInfo", "Collection", "Game", "SlugRom", "RomCrc", "RomTag", "IndexedRom",
+	"Genre", "Developer", "Publisher", "ImageBlob",
+}
+
+// HasTable checks for a table, for MGDBs built before it was added
+func HasTable(db *sql.DB, table string) (bool, error) {
+	count := 0
+	err := db.QueryRow("select count(*) from sqlite_master where type = 'table' and name = ?", table).Scan(&count)
+	return count > 0, err
+}
+
+// CountRows counts a table's rows, table names are not escaped
+func CountRows(db *sql.DB, table string) (int, error) {
+	count := 0
+	err := db.QueryRow(fmt.Sprintf("select count(*) from %v", table)).Scan(&count)
+	return count, err
+}
+
+// GameCoverage counts games with each kind of metadata.
+// Images only count when their blob is present in ImageBlob.
+type GameCoverage struct {
+	Games        int
+	Indexed      int
+	Screenshots  int
+	TitleScreens int
+	Descriptions int
+	ReleaseDates int
+	Developers   int
+	Publishers   int
+	Genres       int
+}
+
+// GetGameCoverage skips the GameID 0 placeholder for loose ROMs
+func GetGameCoverage(db *sql.DB) (GameCoverage, error) {
+	coverage := GameCoverage{}
+	err := db.QueryRow(
+		"select count(*), "+
+			"coalesce(sum(IsIndexed != 0), 0), "+
+			"coalesce(sum(ScreenshotHash in (select Hash from ImageBlob)), 0), "+
+			"coalesce(sum(TitleScreenHash in (select Hash from ImageBlob)), 0), "+
+			"coalesce(sum(trim(Description) != ''), 0), "+
+			"coalesce(sum(ReleaseDate != ''), 0), "+
+			"coalesce(sum(DeveloperID != 0), 0), "+
+			"coalesce(sum(PublisherID != 0), 0), "+
+			"coalesce(sum(GenreID != 0), 0) "+
+			"from Game where GameID != 0",
+	).Scan(
+		&coverage.Games,
+		&coverage.Indexed,
+		&coverage.Screenshots,
+		&coverage.TitleScreens,
+		&coverage.Descriptions,
+		&coverage.ReleaseDates,
+		&coverage.Developers,
+		&coverage.Publishers,
+		&coverage.Genres,
+	)
+	return coverage, err
+}
+
+// NameCount is a lookup name with the number of games using it
+type NameCount struct {
+	Name  string
+	Count int
+}
+
+// GetTopGenres orders genres by game count, limit 0 is unlimited
+func GetTopGenres(db *sql.DB, limit int) ([]NameCount, error) {
+	genres := []NameCount{}
+	query := "select ge.Name, count(*) as GameCount from Game g " +
+		"join Genre ge on ge.GenreID = g.GenreID " +
+		"where g.GameID != 0 group by ge.GenreID order by GameCount desc, ge.Name collate nocase"
+	if limit > 0 {
+		query += fmt.Sprintf(" limit %v", limit)
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return genres, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		genre := NameCount{}
+		if err := rows.Scan(&genre.Name, &genre.Count); err != nil {
+			return genres, err
+		}
+		genres = append(genres, genre)
+	}
+	return genres, rows.Err()
+}
+
+// GetImageBlobSizes returns blob sizes in bytes, smallest first
+func GetImageBlobSizes(db *sql.DB) ([]int64, error) {
+	sizes := []int64{}
+	rows, err := db.Query("select length(Bytes) as Size from ImageBlob order by Size")
+	if err != nil {
+		return sizes, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var size int64
+		if err := rows.Scan(&size); err != nil {
+			return sizes, err
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, rows.Err()
+}
+
+// GetRomCrcSet returns RomCrc CRC32s lowercased, for coverage against RDB CRCs
+func GetRomCrcSet(db *sql.DB) (map[string]bool, error) {
+	crcs := make(map[string]bool)
+	romCrcs, err := GetRomCrcs(db)
+	for _, romCrc := range romCrcs {
+		crcs[strings.ToLower(romCrc.CRC32)] = true
+	}
+	return crcs, err
+}
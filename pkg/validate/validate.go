@@ -0,0 +1,238 @@
+package validate
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/mister"
+	"github.com/BossRighteous/MiSTer_Games_Data_Utils/pkg/sqlite"
+)
+
+// MaxExamples caps the failing rows listed per check
+const MaxExamples = 5
+
+// Result of one check, it passes without failures
+type Result struct {
+	Check    string   `json:"check"`
+	Failures int      `json:"failures"`
+	Examples []string `json:"examples,omitempty"`
+	Note     string   `json:"note,omitempty"`
+}
+
+func (result *Result) fail(example string) {
+	result.Failures++
+	if len(result.Examples) < MaxExamples {
+		result.Examples = append(result.Examples, example)
+	}
+}
+
+type Report struct {
+	Path    string    `json:"path"`
+	Passed  bool      `json:"passed"`
+	Results []*Result `json:"results"`
+}
+
+// Each query selects one description per failing row
+type refCheck struct {
+	check string
+	query string
+}
+
+var refChecks = []refCheck{
+	{"Game.GenreID in Genre",
+		"select GameID || ' ' || Name || ': GenreID ' || GenreID from Game where GenreID not in (select GenreID from Genre)"},
+	{"Game.DeveloperID in Developer",
+		"select GameID || ' ' || Name || ': DeveloperID ' || DeveloperID from Game where DeveloperID not in (select DeveloperID from Developer)"},
+	{"Game.PublisherID in Publisher",
+		"select GameID || ' ' || Name || ': PublisherID ' || PublisherID from Game where PublisherID not in (select PublisherID from Publisher)"},
+	{"SlugRom.GameID in Game",
+		"select Slug || ': GameID ' || GameID from SlugRom where GameID not in (select GameID from Game)"},
+	{"IndexedRom.GameID in Game",
+		"select Path || ': GameID ' || GameID from IndexedRom where GameID not in (select GameID from Game)"},
+	{"Game.ScreenshotHash in ImageBlob",
+		"select GameID || ' ' || Name || ': ' || ScreenshotHash from Game " +
+			"where coalesce(ScreenshotHash, '') != '' and ScreenshotHash not in (select Hash from ImageBlob)"},
+	{"Game.TitleScreenHash in ImageBlob",
+		"select GameID || ' ' || Name || ': ' || TitleScreenHash from Game " +
+			"where coalesce(TitleScreenHash, '') != '' and TitleScreenHash not in (select Hash from ImageBlob)"},
+	{"ImageBlob referenced by Game",
+		"select Hash from ImageBlob where Hash not in (select ScreenshotHash from Game where ScreenshotHash is not null) " +
+			"and Hash not in (select TitleScreenHash from Game where TitleScreenHash is not null)"},
+}
+
+// Merged MGDBs key slugs per collection
+const romCrcQuery = "select CRC32 || ': ' || Slug from RomCrc where Slug not in (select Slug from SlugRom)"
+const mergedRomCrcQuery = "select CRC32 || ': ' || Slug from RomCrc r where not exists " +
+	"(select 1 from SlugRom s where s.Slug = r.Slug and s.CollectionID = r.CollectionID)"
+
+func runQuery(db *sql.DB, result *Result, query string) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		example := ""
+		if err := rows.Scan(&example); err != nil {
+			return err
+		}
+		result.fail(example)
+	}
+	return rows.Err()
+}
+
+var (
+	buildDatePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	shaPattern       = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	revisionPattern  = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+func checkInfo(db *sql.DB) (*Result, error) {
+	result := &Result{Check: "MGDBInfo"}
+	count, err := sqlite.CountRows(db, "MGDBInfo")
+	if err != nil {
+		return result, err
+	}
+	if count != 1 {
+		result.fail(fmt.Sprintf("%v rows, expected 1", count))
+		return result, nil
+	}
+	info, err := sqlite.GetMGDBInfo(db)
+	if err != nil {
+		return result, err
+	}
+	for field, value := range map[string]string{
+		"CollectionName": info.CollectionName,
+		"MGDBVersion":    info.MGDBVersion,
+		"Description":    info.Description,
+	} {
+		if strings.TrimSpace(value) == "" {
+			result.fail(field + " is empty")
+		}
+	}
+	// Merged MGDBs span folders and list their sources in Collection
+	merged, err := sqlite.HasTable(db, "Collection")
+	if err != nil {
+		return result, err
+	}
+	if strings.TrimSpace(info.GamesFolder) == "" && !merged {
+		result.fail("GamesFolder is empty")
+	}
+	if _, err := time.Parse("2006-01-02", info.BuildDate); err != nil || !buildDatePattern.MatchString(info.BuildDate) {
+		result.fail(fmt.Sprintf("BuildDate %q is not YYYY-MM-DD", info.BuildDate))
+	}
+	if info.SupportedSystemIds == "" {
+		result.fail("SupportedSystemIds is empty")
+	}
+	for _, systemID := range strings.Split(info.SupportedSystemIds, ",") {
+		if _, ok := mister.Systems[systemID]; !ok && systemID != "" {
+			result.fail(fmt.Sprintf("SupportedSystemIds has unknown system %q", systemID))
+		}
+	}
+	if info.RdbRevision != "" && !revisionPattern.MatchString(info.RdbRevision) {
+		result.fail(fmt.Sprintf("RdbRevision %q is not a commit SHA", info.RdbRevision))
+	}
+	if info.RdbSHA256 != "" && !shaPattern.MatchString(info.RdbSHA256) {
+		result.fail(fmt.Sprintf("RdbSHA256 %q is not a sha256", info.RdbSHA256))
+	}
+	return result, nil
+}
+
+// Blobs are keyed by the MD5 of their bytes and must decode. Formats the
+// standard library can't decode are only sniffed.
+func checkBlobs(db *sql.DB) (*Result, *Result, error) {
+	md5s := &Result{Check: "ImageBlob.Hash is MD5 of Bytes"}
+	decodes := &Result{Check: "ImageBlob decodes"}
+	rows, err := db.Query("select Hash, Bytes from ImageBlob")
+	if err != nil {
+		return md5s, decodes, err
+	}
+	defer rows.Close()
+	undecoded := make(map[string]int)
+	for rows.Next() {
+		hash := ""
+		data := []byte{}
+		if err := rows.Scan(&hash, &data); err != nil {
+			return md5s, decodes, err
+		}
+		if sum := sqlite.GetMD5Hash(data); sum != hash {
+			md5s.fail(fmt.Sprintf("%v: bytes hash to %v", hash, sum))
+		}
+		contentType := http.DetectContentType(data)
+		switch contentType {
+		case "image/png", "image/jpeg", "image/gif":
+			if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+				decodes.fail(fmt.Sprintf("%v: %v", hash, err))
+			}
+		case "image/webp", "image/bmp":
+			undecoded[contentType]++
+		default:
+			decodes.fail(fmt.Sprintf("%v: not an image (%v)", hash, contentType))
+		}
+	}
+	if len(undecoded) > 0 {
+		notes := []string{}
+		for contentType, count := range undecoded {
+			notes = append(notes, fmt.Sprintf("%v %v", count, contentType))
+		}
+		decodes.Note = "not decoded: " + strings.Join(notes, ", ")
+	}
+	return md5s, decodes, rows.Err()
+}
+
+// MGDB runs every check against the MGDB at path, with its media pack if present
+func MGDB(path string) (*Report, error) {
+	report := &Report{Path: path, Results: []*Result{}}
+	db, err := sqlite.OpenMGDB(path)
+	if err != nil {
+		return report, err
+	}
+	defer db.Close()
+
+	info, err := checkInfo(db)
+	if err != nil {
+		return report, fmt.Errorf("MGDBInfo: %w", err)
+	}
+	report.Results = append(report.Results, info)
+
+	checks := append([]refCheck{}, refChecks...)
+	merged, err := sqlite.HasColumn(db, "RomCrc", "CollectionID")
+	if err != nil {
+		return report, err
+	}
+	if merged {
+		checks = append(checks, refCheck{"RomCrc.Slug in SlugRom", mergedRomCrcQuery})
+	} else {
+		checks = append(checks, refCheck{"RomCrc.Slug in SlugRom", romCrcQuery})
+	}
+	for _, check := range checks {
+		result := &Result{Check: check.check}
+		if err := runQuery(db, result, check.query); err != nil {
+			return report, fmt.Errorf("%v: %w", check.check, err)
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	md5s, decodes, err := checkBlobs(db)
+	if err != nil {
+		return report, fmt.Errorf("ImageBlob: %w", err)
+	}
+	report.Results = append(report.Results, md5s, decodes)
+
+	report.Passed = true
+	for _, result := range report.Results {
+		if result.Failures > 0 {
+			report.Passed = false
+		}
+	}
+	return report, nil
+}